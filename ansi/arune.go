@@ -129,3 +129,30 @@ mainLoop:
 	astring.Attrs = astring.Attrs[:ri]
 	return astring
 }
+
+// Bytes returns text of Astring with attributes encoded as escape sequences, NewAstring of it is the same Astring
+func (a Astring) Bytes() []byte {
+	var buf bytes.Buffer
+	var prev RuneAttr
+	for i, r := range a.Runes {
+		if attr := a.Attrs[i]; attr != prev {
+			// every sequence resets attributes, so all of them are set at once
+			var codes []string
+			for _, code := range []uint8{attr.Style, attr.Fg, attr.Bg} {
+				if code != 0 {
+					codes = append(codes, strconv.Itoa(int(code)))
+				}
+			}
+			if len(codes) == 0 {
+				codes = []string{"0"}
+			}
+			buf.WriteString("\x1b[" + strings.Join(codes, ";") + "m")
+			prev = attr
+		}
+		buf.WriteRune(r)
+	}
+	if prev != (RuneAttr{}) {
+		buf.WriteString("\x1b[0m")
+	}
+	return buf.Bytes()
+}
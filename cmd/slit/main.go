@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"regexp"
+	"strconv"
 
 	"context"
 	"time"
@@ -21,6 +23,17 @@ var (
 	follow     bool
 	keepChars  int
 	filtersOpt string
+	merge      bool
+	followName bool
+	start      string
+	before     int
+	after      int
+	around     int
+	records    string
+	jsonMode   bool
+	jsonTmpl   string
+	since      string
+	until      string
 )
 
 func main() {
@@ -30,6 +43,7 @@ func main() {
 	flag.StringVarP(&outPath, "output", "O", "", "Sets stdin cache location, if not set tmp file used, if set file preserved")
 	flag.BoolVar(&logging.Config.Enabled, "debug", false, "Enables debug messages, written to /tmp/slit.log")
 	flag.BoolVarP(&follow, "follow", "f", false, "Will follow file/stdin")
+	flag.BoolVarP(&followName, "follow-name", "F", false, "Will follow file by name, reopening it after rotation. Implies --follow")
 	showVersion := false
 	alwaysTermMode := false
 	waitForShortStdin := 10000
@@ -38,7 +52,20 @@ func main() {
 	flag.IntVarP(&keepChars, "keep-chars", "K", 0, "Initial num of chars kept during horizontal scrolling")
 	flag.IntVar(&waitForShortStdin, "short-stdin-timeout", 10000, "Maximum duration(ms) to wait for delayed short stdin(won't delay long stdin)")
 	flag.StringVarP(&filtersOpt, "filters", "", "", "Filters file names or inline filters separated by semicolon")
-	flag.Parse()
+	flag.StringVar(&start, "start", "", "Initial position: G for the end, N for line number, bN for byte offset, /pattern for first match. Same as less-style +G, +N, +/pattern")
+	flag.IntVarP(&after, "after-context", "A", 0, "Lines shown after every line included by filters")
+	flag.IntVarP(&before, "before-context", "B", 0, "Lines shown before every line included by filters")
+	flag.IntVarP(&around, "context", "C", 0, "Lines shown before and after every line included by filters, unless set by -A or -B")
+	flag.StringVar(&records, "record-start", "", "Regex matching first line of multi-line record, i.e. '^\\d{4}-\\d{2}-\\d{2}'. Following lines not matching it are shown, filtered and saved together with it")
+	flag.BoolVar(&jsonMode, "json", false, "Renders JSON lines with --json-template, detected by input if not set. --json=false disables it")
+	flag.StringVar(&jsonTmpl, "json-template", slit.DefaultJSONTemplate, "Keys shown first in JSON mode, alternative names separated by |, ... for the rest of keys as key=value")
+	flag.StringVar(&since, "since", "", "Shows lines with timestamp at or after given time, i.e. 14:00, '2019-03-01 14:00' or 30m for 30 minutes ago")
+	flag.StringVar(&until, "until", "", "Shows lines with timestamp before given time, same format as --since")
+	flag.BoolVarP(&merge, "merge", "m", false, "Merge all files into one view, interleaving lines by their timestamps")
+	args, command := splitCommand(os.Args[1:])
+	flag.CommandLine.Parse(args)
+	follow = follow || followName
+	paths := pathArgs()
 
 	if showVersion {
 		fmt.Println("Slit Version: ", VERSION)
@@ -48,52 +75,194 @@ func main() {
 	stdinStat, _ := os.Stdin.Stat()
 	stdoutStat, _ := os.Stdout.Stat()
 
-	var s *slit.Slit
+	var slits []*slit.Slit
 	var err error
 
-	if isPipe(stdinStat) && flag.NArg() == 0 {
+	if len(command) != 0 {
+		s, err := slit.NewFromCommand(command[0], command[1:]...)
+		exitOnErr(err)
+		slits = append(slits, s)
+	} else if isPipe(stdinStat) && len(paths) == 0 {
 		if isPipe(stdoutStat) {
-			outputToStdout(os.Stdin)
+			outputDecompressed(ctx, os.Stdin)
 			return
 		}
-		s, err = slit.NewFromStdin()
+		s, err := slit.NewFromStdin()
 		exitOnErr(err)
+		slits = append(slits, s)
 	} else {
-		if flag.NArg() != 1 {
-			fmt.Fprintln(os.Stderr, "Only viewing of one file or from STDIN is supported")
+		if len(paths) == 0 {
+			fmt.Fprintln(os.Stderr, "No file to view, pass file paths or pipe data to STDIN")
 			os.Exit(1)
 		}
-		path := flag.Arg(0)
 
 		if isPipe(stdoutStat) {
-			f, err := os.Open(path)
-			exitOnErr(err)
-			outputToStdout(f)
+			for _, path := range paths {
+				f, err := os.Open(path)
+				exitOnErr(err)
+				outputDecompressed(ctx, f)
+				f.Close()
+			}
 			return
 		}
 
-		s, err = slit.NewFromFilepath(path)
-		exitOnErr(err)
+		for _, path := range paths {
+			var s *slit.Slit
+			if followName {
+				s, err = slit.NewFollowingName(path)
+			} else {
+				s, err = slit.NewFromFilepath(path)
+			}
+			exitOnErr(err)
+			slits = append(slits, s)
+		}
+
+		if merge {
+			for _, s := range slits {
+				s.SetFollow(follow) // merging checks it once reaches the end of inputs
+			}
+			s, err := slit.NewMerged(slits)
+			exitOnErr(err)
+			slits = []*slit.Slit{s}
+		}
+	}
+
+	for _, s := range slits {
+		defer s.Shutdown()
 	}
 
-	defer s.Shutdown()
+	if start != "" {
+		for _, s := range slits {
+			exitOnErr(setStart(s, start))
+		}
+	}
 
-	if !alwaysTermMode && tryDirectOutputIfShort(s, ctx, waitForShortStdin) {
+	// merged lines are labeled by their sources and start position is shown only in term mode
+	if !alwaysTermMode && !followName && !merge && start == "" && len(slits) == 1 && tryDirectOutputIfShort(slits[0], ctx, waitForShortStdin) {
 		return
 	}
 
-	if filtersOpt != "" {
-		initFilters, err := filters.ParseFiltersOpt(filtersOpt)
+	if before == 0 {
+		before = around
+	}
+	if after == 0 {
+		after = around
+	}
+	var recordStart *regexp.Regexp
+	if records != "" {
+		recordStart, err = regexp.Compile(records)
+		exitOnErr(err)
+	}
+	jsonSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "json" {
+			jsonSet = true
+		}
+	})
+
+	for _, s := range slits {
+		// every tab gets its own filters, they are changed independently
+		initFilters, err := parseInitFilters()
 		exitOnErr(err)
 		s.SetFilters(initFilters)
+		s.SetOutPath(outPath) // TODO: This is not really used right now, NewFromStdin uses config before it is set here
+		// Probably should pass config to all slit constructors, with sane defaults
+		s.SetFollow(follow)
+		s.SetKeepChars(keepChars)
+		s.SetContext(before, after)
+		if recordStart != nil {
+			s.SetRecordStart(recordStart)
+		}
+		if jsonSet {
+			s.SetJSON(jsonMode)
+		}
+		s.SetJSONTemplate(jsonTmpl)
+	}
+
+	slit.DisplayTabs(slits...)
+}
+
+// parseInitFilters returns filters given by --filters, --since and --until
+func parseInitFilters() ([]*filters.Filter, error) {
+	var initFilters []*filters.Filter
+	var err error
+	if filtersOpt != "" {
+		initFilters, err = filters.ParseFiltersOpt(filtersOpt)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if since != "" || until != "" {
+		// added last, so lines out of range are not included back by union filters
+		timeFilter, err := filters.NewFilter([]rune(since+".."+until), filters.FilterIntersect, filters.Time)
+		if err != nil {
+			return nil, err
+		}
+		initFilters = append(initFilters, timeFilter)
 	}
+	return initFilters, nil
+}
 
-	s.SetOutPath(outPath) // TODO: This is not really used right now, NewFromStdin uses config before it is set here
-	// Probably should pass config to all slit constructors, with sane defaults
-	s.SetFollow(follow)
-	s.SetKeepChars(keepChars)
+// pathArgs returns positional arguments except less-style +<start position>, which is assigned to start option
+func pathArgs() []string {
+	var paths []string
+	for _, arg := range flag.Args() {
+		if len(arg) > 1 && arg[0] == '+' && start == "" {
+			start = arg[1:]
+			continue
+		}
+		paths = append(paths, arg)
+	}
+	return paths
+}
 
-	s.Display()
+// setStart parses start position spec
+func setStart(s *slit.Slit, spec string) error {
+	switch {
+	case spec == "G":
+		s.SetStartEnd()
+		return nil
+	case spec[0] == '/':
+		if len(spec) == 1 {
+			break
+		}
+		s.SetStartSearch(spec[1:])
+		return nil
+	case spec[0] == 'b':
+		offset, err := strconv.ParseInt(spec[1:], 10, 64)
+		if err != nil || offset < 0 {
+			break
+		}
+		s.SetStartOffset(offset)
+		return nil
+	default:
+		line, err := strconv.ParseInt(spec, 10, 64)
+		if err != nil || line < 1 {
+			break
+		}
+		s.SetStartLine(line)
+		return nil
+	}
+	return fmt.Errorf("Bad start position \"%s\", expected G, N, bN or /pattern", spec)
+}
+
+// splitCommand separates slit arguments from command to run, given after "--".
+// Existing file given after "--" is opened as usual, "--" only ends flags then, i.e. slit -- -weird-filename
+func splitCommand(args []string) ([]string, []string) {
+	for i, arg := range args {
+		if arg != "--" {
+			continue
+		}
+		command := args[i+1:]
+		if len(command) == 0 {
+			return args, nil
+		}
+		if _, err := os.Stat(command[0]); err == nil {
+			return args, nil
+		}
+		return args[:i], command
+	}
+	return args, nil
 }
 
 func tryDirectOutputIfShort(s *slit.Slit, ctx context.Context, durationMs int) bool {
@@ -119,6 +288,13 @@ func outputToStdout(file *os.File) {
 	io.Copy(os.Stdout, file)
 }
 
+func outputDecompressed(ctx context.Context, file *os.File) {
+	reader, err := slit.DecompressingReader(ctx, file)
+	exitOnErr(err)
+	io.Copy(os.Stdout, reader)
+	reader.Close()
+}
+
 func isPipe(info os.FileInfo) bool {
 	if info == nil {
 		return false
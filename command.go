@@ -0,0 +1,195 @@
+package slit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/nsf/termbox-go"
+	"github.com/tigrawap/slit/logging"
+)
+
+// command runs external process writing its stdout and stderr into cache file
+type command struct {
+	name    string
+	args    []string
+	ctx     context.Context
+	cache   *os.File
+	wg      *sync.WaitGroup
+	lock    sync.Mutex // guards everything below and writes to cache
+	cancel  context.CancelFunc
+	done    chan struct{} // closed once current run finished and all of its output is written
+	running bool
+	killed  bool
+	err     error
+	changed chan struct{} // notified on every change of state
+}
+
+// NewFromCommand runs the command and displays its combined output, lines written to stderr are tinted.
+// Command is killed on shutdown, it also can be killed or re-run from UI
+func NewFromCommand(name string, args ...string) (*Slit, error) {
+	cacheFile, err := mkCacheFile()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(cacheFile.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	s := New(f)
+	s.isCacheFile = true
+	s.name = strings.Join(append([]string{name}, args...), " ")
+	s.command = &command{
+		name:    name,
+		args:    args,
+		ctx:     s.ctx,
+		cache:   cacheFile,
+		wg:      &s.wg,
+		changed: make(chan struct{}, 1),
+	}
+	if err := s.command.start(); err != nil {
+		return nil, err
+	}
+	s.streamDone = s.command.done
+	return s, nil
+}
+
+func (c *command) start() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.ctx.Err(); err != nil {
+		return err // shut down while restarting
+	}
+	cmd := exec.Command(c.name, c.args...)
+	runInGroup(cmd)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	c.running, c.killed, c.err = true, false, nil
+	c.notify()
+
+	output := sync.WaitGroup{}
+	output.Add(2)
+	go func() { c.copyLines(stdout, ""); output.Done() }()
+	go func() { c.copyLines(stderr, "\x1b[31m"); output.Done() }()
+	c.wg.Add(1)
+	go func(done chan struct{}) {
+		defer c.wg.Done()
+		select {
+		case <-ctx.Done():
+			select {
+			case <-done:
+			default:
+				killGroup(cmd) // children might hold output open after command itself is killed
+			}
+		case <-done:
+		}
+	}(c.done)
+	c.wg.Add(1)
+	go func(done chan struct{}) {
+		defer c.wg.Done()
+		defer cancel()
+		output.Wait()
+		err := cmd.Wait()
+		c.lock.Lock()
+		c.running = false
+		c.err = err
+		close(done)
+		c.notify()
+		c.lock.Unlock()
+	}(c.done)
+	return nil
+}
+
+// copyLines writes lines of reader into cache, wrapping them with color escape sequence if given
+func (c *command) copyLines(r io.Reader, color string) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			line = []byte(strings.TrimSuffix(string(line), "\n"))
+			if color != "" {
+				line = []byte(color + string(line) + "\x1b[0m")
+			}
+			c.lock.Lock()
+			c.cache.Write(append(line, '\n'))
+			c.lock.Unlock()
+		}
+		if err != nil {
+			if err != io.EOF {
+				logging.Debug("Error reading command output:", err)
+			}
+			return
+		}
+	}
+}
+
+func (c *command) notify() {
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// kill stops the command, returns channel closed once it is finished
+func (c *command) kill() <-chan struct{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.running {
+		c.killed = true
+		c.cancel()
+	}
+	return c.done
+}
+
+// restart kills the command if running and starts it again with empty output
+func (c *command) restart() error {
+	<-c.kill()
+	c.lock.Lock()
+	c.cache.Truncate(0)
+	c.cache.Seek(0, io.SeekStart)
+	c.lock.Unlock()
+	return c.start()
+}
+
+func (c *command) streamDone() <-chan struct{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.done
+}
+
+// status returns short description of command state and color it should be shown with
+func (c *command) status() (string, termbox.Attribute) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	switch {
+	case c.running:
+		return "[running]", termbox.ColorYellow
+	case c.killed:
+		return "[killed]", termbox.ColorRed
+	case c.err == nil:
+		return "[exit 0]", termbox.ColorGreen
+	}
+	if exitErr, ok := c.err.(*exec.ExitError); ok {
+		return fmt.Sprintf("[exit %d]", exitErr.ExitCode()), termbox.ColorRed
+	}
+	return fmt.Sprintf("[%s]", c.err), termbox.ColorRed
+}
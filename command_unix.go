@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package slit
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// runInGroup makes command a leader of new process group, so all of its children can be killed together
+func runInGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killGroup(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
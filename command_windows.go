@@ -0,0 +1,11 @@
+package slit
+
+import (
+	"os/exec"
+)
+
+func runInGroup(cmd *exec.Cmd) {}
+
+func killGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
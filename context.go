@@ -0,0 +1,214 @@
+package slit
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tigrawap/slit/ansi"
+)
+
+// contextLines is number of lines shown before and after every line included by filters, like grep -B and -A
+type contextLines struct {
+	before, after int
+}
+
+var errInvalidContext = errors.New("Context should be N or BEFORE,AFTER")
+
+func (c contextLines) enabled() bool {
+	return c.before > 0 || c.after > 0
+}
+
+// String returns context in the format accepted by parseContextLines
+func (c contextLines) String() string {
+	if c.before == c.after {
+		return strconv.Itoa(c.before)
+	}
+	return fmt.Sprintf("%d,%d", c.before, c.after)
+}
+
+// status returns context in grep flags notation, i.e. -C 3 or -B 2 -A 5
+func (c contextLines) status() string {
+	switch {
+	case c.before == c.after:
+		return fmt.Sprintf("-C %d", c.before)
+	case c.after == 0:
+		return fmt.Sprintf("-B %d", c.before)
+	case c.before == 0:
+		return fmt.Sprintf("-A %d", c.after)
+	}
+	return fmt.Sprintf("-B %d -A %d", c.before, c.after)
+}
+
+// parseContextLines accepts N for N lines on both sides or BEFORE,AFTER, empty string disables context
+func parseContextLines(str string) (contextLines, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return contextLines{}, nil
+	}
+	parts := strings.Split(str, ",")
+	if len(parts) > 2 {
+		return contextLines{}, errInvalidContext
+	}
+	values := make([]int, len(parts))
+	for i, part := range parts {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || value < 0 {
+			return contextLines{}, errInvalidContext
+		}
+		values[i] = value
+	}
+	if len(values) == 1 {
+		return contextLines{values[0], values[0]}, nil
+	}
+	return contextLines{values[0], values[1]}, nil
+}
+
+// contextFilter decides which lines excluded by filters are shown as context of included ones.
+// Lines are pushed in reading order, which is backwards for GetBack
+type contextFilter struct {
+	leading  int       // context lines preceding included line in reading order
+	trailing int       // context lines following included line in reading order
+	reverse  bool      // lines are read from the end of the file
+	start    Offset    // lines before start in reading order are only read to know context of the following ones
+	pending  []PosLine // excluded lines, shown if one of next leading lines is included
+	left     int       // number of lines still shown after last included one
+	skipped  bool      // some lines were not shown since last shown line
+	held     *Line     // reading backwards, gap before the line is known only once next shown line is found
+	out      []Line
+}
+
+func newContextFilter(c contextLines, reverse bool, start Offset) *contextFilter {
+	if reverse {
+		return &contextFilter{leading: c.after, trailing: c.before, reverse: true, start: start}
+	}
+	return &contextFilter{leading: c.before, trailing: c.after, start: start}
+}
+
+// push takes next line as read from the file and its filtered version, returns lines to show.
+// Returned slice is valid until next call
+func (c *contextFilter) push(raw PosLine, l Line) []Line {
+	c.out = c.out[:0]
+	if l.Pos.Line != POS_FILTERED_OUT {
+		for _, p := range c.pending {
+			c.emit(contextLine(p))
+		}
+		c.pending = c.pending[:0]
+		c.emit(l)
+		c.left = c.trailing
+		return c.out
+	}
+	switch {
+	case c.left > 0:
+		c.left--
+		c.emit(contextLine(raw))
+	case c.leading == 0:
+		c.skipped = true
+	default:
+		if len(c.pending) == c.leading {
+			copy(c.pending, c.pending[1:])
+			c.pending = c.pending[:len(c.pending)-1]
+			c.skipped = true
+		}
+		c.pending = append(c.pending, raw)
+	}
+	return c.out
+}
+
+func contextLine(raw PosLine) Line {
+	return Line{Str: ansi.NewAstring(raw.b), Pos: raw.Pos, Context: true}
+}
+
+// finish returns line held back when reading backwards, once the start of the file is reached
+func (c *contextFilter) finish() []Line {
+	c.out = c.out[:0]
+	if c.held != nil {
+		c.held.GapBefore = c.skipped || len(c.pending) != 0
+		c.out = append(c.out, *c.held)
+		c.held = nil
+	}
+	return c.out
+}
+
+func (c *contextFilter) emit(l Line) {
+	skipped := c.skipped
+	c.skipped = false
+	if !c.reverse {
+		if l.Offset >= c.start {
+			l.GapBefore = skipped
+			c.out = append(c.out, l)
+		}
+		return
+	}
+	if c.held != nil {
+		c.held.GapBefore = skipped
+		c.out = append(c.out, *c.held)
+		c.held = nil
+	}
+	if l.Offset <= c.start {
+		c.held = &l
+	}
+}
+
+// linesBefore returns start of the line n lines before the one starting at offset, or start of the file,
+// with number of lines actually moved
+func (f *Fetcher) linesBefore(offset Offset, n int) (Offset, int) {
+	buf := make([]byte, fetchBackStep)
+	moved := 0
+	end := offset - 1 // newline ending previous line
+	for end > 0 && moved < n {
+		start := end - fetchBackStep
+		if start < 0 {
+			start = 0
+		}
+		chunk := buf[:end-start]
+		if _, err := f.reader.ReadAt(chunk, int64(start)); err != nil {
+			return offset, moved
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] == '\n' {
+				moved++
+				offset = start + Offset(i) + 1
+				if moved == n {
+					return offset, moved
+				}
+			}
+		}
+		end = start
+	}
+	if offset > 0 && moved < n {
+		moved++
+		offset = 0
+	}
+	return offset, moved
+}
+
+// linesAfter returns start of the line n lines after the one containing offset, or start of the last line,
+// with number of lines actually moved
+func (f *Fetcher) linesAfter(offset Offset, n int) (Offset, int) {
+	size := f.size()
+	buf := make([]byte, fetchBackStep)
+	moved := 0
+	for pos := offset; pos < size && moved < n; {
+		chunk := buf
+		if size-pos < Offset(len(chunk)) {
+			chunk = chunk[:size-pos]
+		}
+		read, err := f.reader.ReadAt(chunk, int64(pos))
+		for i, b := range chunk[:read] {
+			if b == '\n' && pos+Offset(i)+1 < size {
+				moved++
+				offset = pos + Offset(i) + 1
+				if moved == n {
+					return offset, moved
+				}
+			}
+		}
+		if err != nil || read == 0 {
+			break
+		}
+		pos += Offset(read)
+	}
+	return offset, moved
+}
@@ -0,0 +1,108 @@
+package slit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+)
+
+type compression struct {
+	name      string
+	magic     []byte
+	binary    string // installed binary decompressing the data, empty if it is supported by standard library
+	newReader func(ctx context.Context, r io.Reader) (io.ReadCloser, error)
+}
+
+var compressions = []compression{
+	{
+		name:  "gzip",
+		magic: []byte{0x1f, 0x8b},
+		newReader: func(ctx context.Context, r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		name:  "bzip2",
+		magic: []byte("BZh"),
+		newReader: func(ctx context.Context, r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bzip2.NewReader(r)), nil
+		},
+	},
+	{
+		name:      "xz",
+		magic:     []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		binary:    "xz",
+		newReader: externalDecompressor("xz"),
+	},
+	{
+		name:      "zstd",
+		magic:     []byte{0x28, 0xb5, 0x2f, 0xfd},
+		binary:    "zstd",
+		newReader: externalDecompressor("zstd"),
+	},
+}
+
+const maxMagicLength = 6
+
+// detectCompression returns compression matching magic bytes in the header, nil if data is not compressed
+func detectCompression(header []byte) *compression {
+	for i := range compressions {
+		if bytes.HasPrefix(header, compressions[i].magic) {
+			return &compressions[i]
+		}
+	}
+	return nil
+}
+
+// available returns error if binary needed for decompression is not installed
+func (c *compression) available() error {
+	if c.binary == "" {
+		return nil
+	}
+	if _, err := exec.LookPath(c.binary); err != nil {
+		return fmt.Errorf("%s is needed to decompress %s input: %v", c.binary, c.name, err)
+	}
+	return nil
+}
+
+// externalDecompressor uses installed binary for formats not supported by standard library
+func externalDecompressor(name string) func(ctx context.Context, r io.Reader) (io.ReadCloser, error) {
+	return func(ctx context.Context, r io.Reader) (io.ReadCloser, error) {
+		cmd := exec.CommandContext(ctx, name, "-dc")
+		cmd.Stdin = r
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		return &commandReader{out, cmd}, nil
+	}
+}
+
+type commandReader struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (r *commandReader) Close() error {
+	r.ReadCloser.Close()
+	return r.cmd.Wait()
+}
+
+// DecompressingReader returns reader of decompressed data if src is compressed, otherwise data is passed as is
+func DecompressingReader(ctx context.Context, src io.Reader) (io.ReadCloser, error) {
+	reader := bufio.NewReaderSize(src, 64*1024)
+	header, _ := reader.Peek(maxMagicLength)
+	if c := detectCompression(header); c != nil {
+		return c.newReader(ctx, reader)
+	}
+	return ioutil.NopCloser(reader), nil
+}
@@ -4,11 +4,14 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"github.com/nsf/termbox-go"
 	"github.com/tigrawap/slit/ansi"
 	"github.com/tigrawap/slit/filters"
 	"github.com/tigrawap/slit/logging"
+	"github.com/tigrawap/slit/timestamps"
 	"io"
 	"os"
+	"regexp"
 	"sort"
 	"sync"
 	"time"
@@ -16,7 +19,7 @@ import (
 
 type Fetcher struct {
 	mLock            sync.RWMutex
-	lineMap          map[Offset]LineNo // caches Offset of some lines, meanwhile only last one, when available
+	index            *lineIndex
 	reader           *os.File
 	lock             sync.RWMutex
 	lineReader       *bufio.Reader
@@ -25,6 +28,12 @@ type Fetcher struct {
 	filters          []*filters.Filter
 	highlightedLines []LineNo
 	filtersEnabled   bool
+	lineContext      contextLines
+	recordStart      *regexp.Regexp // lines not matching it are joined with previous one into a record, nil if disabled
+	bounds           timeBounds     // cached offsets of lines kept by time filter, guarded by mLock
+	streamDone       <-chan struct{} // closed once cache file is completely written, nil for regular files. Guarded by mLock
+	generation       int             // incremented on every reset, guarded by mLock
+	labels           *lineLabels     // labels shown in gutter of merged lines, nil if not merged
 }
 
 const (
@@ -57,54 +66,99 @@ type PosLine struct {
 type Line struct {
 	Str ansi.Astring
 	Pos
-	Highlighted bool
+	Highlighted    bool
+	HighlightColor termbox.Attribute // set if line is highlighted by ~ filter with color, default highlight otherwise
+	Context        bool              // excluded by filters, but shown as context of included line
+	GapBefore      bool              // lines right before this one are not shown, only set when context is shown
+	Label          ansi.Astring      // shown in gutter before the line, it is not filtered nor searched
 }
 
-type offsetArr []Offset
+// filterChain is what decides which lines are included and highlighted. Slices are replaced on every change
+// and never modified in place, so copy of the chain taken under lock can be used without it
+type filterChain struct {
+	filters          []*filters.Filter
+	highlightedLines []LineNo
+	filtersEnabled   bool
+}
 
-func (a offsetArr) Len() int           { return len(a) }
-func (a offsetArr) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a offsetArr) Less(i, j int) bool { return a[i] < a[j] }
+// chain returns current filter chain, fetcher lock should be held
+func (f *Fetcher) chain() filterChain {
+	return filterChain{f.filters, f.highlightedLines, f.filtersEnabled}
+}
+
+// snapshotChain returns current filter chain, which is not affected by following changes
+func (f *Fetcher) snapshotChain() filterChain {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.chain()
+}
+
+// readingChain returns snapshot of the chain lines are about to be filtered by, with time ranges anchored
+func (f *Fetcher) readingChain() filterChain {
+	chain := f.snapshotChain()
+	f.anchorTimeRanges(chain)
+	return chain
+}
 
-// Line == -1 if Line is excluded
+// Line == -1 if Line is excluded. Fetcher lock should be held
 func (f *Fetcher) filteredLine(l PosLine) Line {
+	return f.chain().filteredLine(l)
+}
+
+func (c filterChain) filteredLine(l PosLine) Line {
 	str := ansi.NewAstring(l.b)
-	if len(f.filters) == 0 && len(f.highlightedLines) == 0 {
-		return Line{str, l.Pos, false}
+	if len(c.filters) == 0 && len(c.highlightedLines) == 0 {
+		return Line{Str: str, Pos: l.Pos}
 	}
 	var filterResult filters.FilterResult
-	for _, highlighted := range f.highlightedLines {
-		logging.Debug(f.highlightedLines, l.Pos.Line)
+	var color termbox.Attribute
+	for _, highlighted := range c.highlightedLines {
+		logging.Debug(c.highlightedLines, l.Pos.Line)
 		if highlighted == l.Pos.Line {
 			filterResult = filters.FilterHighlighted
 			break
 		}
 	}
 
-	for _, filter := range f.filters {
-		if f.filtersEnabled || filter.Action == filters.FilterHighlight {
-			filterResult = filter.TakeAction(str.Runes, filterResult)
+	for _, filter := range c.filters {
+		if filter.Disabled {
+			continue
+		}
+		if c.filtersEnabled || filter.Action == filters.FilterHighlight {
+			result := filter.TakeAction(str.Runes, filterResult)
+			if result == filters.FilterHighlighted && filterResult != filters.FilterHighlighted {
+				color = filter.Color
+			}
+			filterResult = result
 		}
 	}
 	switch filterResult {
 	case filters.FilterExcluded:
 		return Line{Pos: Pos{Line: POS_FILTERED_OUT, Offset: l.Pos.Offset}}
 	case filters.FilterHighlighted:
-		return Line{str, l.Pos, true}
+		return Line{Str: str, Pos: l.Pos, Highlighted: true, HighlightColor: color}
 	default:
-		return Line{str, l.Pos, false}
+		return Line{Str: str, Pos: l.Pos}
 	}
 
 }
 
+// labeled returns line with label of merged line set
+func (f *Fetcher) labeled(l Line) Line {
+	if f.labels != nil {
+		l.Label = f.labels.at(l.Offset)
+	}
+	return l
+}
+
 func newFetcher(reader *os.File, ctx context.Context) *Fetcher {
 	f := &Fetcher{
 		reader:         reader,
-		lineMap:        map[Offset]LineNo{0: 0},
+		index:          newLineIndex(reader),
 		lineReader:     bufio.NewReaderSize(reader, 64*1024),
 		filtersEnabled: true,
 	}
-	go f.gcMap(ctx)
+	go f.index.build(ctx)
 	return f
 }
 
@@ -147,7 +201,7 @@ func (f *Fetcher) readline() ([]byte, Offset, error) {
 	str, err := f.lineReader.ReadBytes('\n')
 	startingOffset := f.lineReaderOffset
 	if len(str) > 0 {
-		if err == io.EOF && config.stdin && !config.isStdinRead() {
+		if err == io.EOF && f.isStreaming() {
 			// Bad idea to remember position when we are not done reading current line
 			f.lineReader = nil
 			f.lineReaderPos = 0
@@ -166,27 +220,41 @@ func (f *Fetcher) readline() ([]byte, Offset, error) {
 
 // Returns 2 channels: for consuming posLines and returning of built Line struct
 // lines guranteed to return in received order with filters applied
-func (f *Fetcher) lineBuilder(ctx context.Context) (chan<- PosLine, <-chan Line) {
+// If contextFilter is given, excluded lines around included ones are returned as well
+func (f *Fetcher) lineBuilder(ctx context.Context, cf *contextFilter) (chan<- PosLine, <-chan Line) {
 	bufSize := 256
 	feeder := make(chan PosLine, bufSize)
 	lines := make(chan Line, bufSize)
 	buffer := make([]Line, bufSize)
+	raw := make([]PosLine, bufSize)
 	var ok bool
 	var l PosLine
 	go func() {
 		bLen := 0
 		wg := sync.WaitGroup{}
+		send := func(l Line) bool {
+			select {
+			case lines <- f.labeled(l):
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
 		flush := func() {
 			wg.Wait()
 			for i := 0; i < bLen; i++ {
+				if cf != nil {
+					for _, l := range cf.push(raw[i], buffer[i]) {
+						if !send(l) {
+							break
+						}
+					}
+					continue
+				}
 				if buffer[i].Pos.Line == POS_FILTERED_OUT {
 					continue //filtered out
 				}
-				select {
-				case lines <- buffer[i]:
-				case <-ctx.Done():
-					break
-				}
+				send(buffer[i])
 			}
 			bLen = 0
 			wg = sync.WaitGroup{}
@@ -201,6 +269,7 @@ func (f *Fetcher) lineBuilder(ctx context.Context) (chan<- PosLine, <-chan Line)
 				if !ok { //feeder closed
 					return
 				}
+				raw[bLen] = l
 				wg.Add(1)
 				go func(i int, l PosLine) {
 					buffer[i] = f.filteredLine(l)
@@ -225,20 +294,37 @@ func (f *Fetcher) Get(ctx context.Context, from Pos) <-chan Line {
 		close(ret)
 		return ret
 	}
-	if from.Line == POS_UNKNOWN {
-		from.Line = f.resolveLine(from.Offset)
+	chain := f.readingChain()
+	startFrom = f.recordAt(chain, startFrom)
+	low, high := f.timeBounds(ctx)
+	if startFrom < low {
+		startFrom = low
+	}
+	if from.Line == POS_UNKNOWN || startFrom != from.Offset {
+		from.Line = f.resolveLine(startFrom)
 	}
 	f.lock.Lock()
+	var cf *contextFilter
+	if f.lineContext.enabled() {
+		// lines before may be included, showing the first lines as their context
+		cf = newContextFilter(f.lineContext, false, startFrom)
+		var moved int
+		startFrom, moved = f.linesBefore(startFrom, f.lineContext.after)
+		if from.Line >= 0 {
+			from.Line -= LineNo(moved)
+		}
+	}
 	f.seek(startFrom)
 	var wg sync.WaitGroup
-	feeder, lines := f.lineBuilder(ctx)
+	feeder, lines := f.lineBuilder(ctx, cf)
 	wg.Add(1)
 	go func(lineNum LineNo) {
 		defer wg.Done()
 		defer close(feeder)
+		records := f.newRecordReader(chain, f.readline)
 		for {
-			str, pos, err := f.readline()
-			if len(str) == 0 && err == io.EOF {
+			str, pos, lines, err := records.read()
+			if (len(str) == 0 && err == io.EOF) || pos >= high {
 				return
 			}
 			select {
@@ -250,7 +336,7 @@ func (f *Fetcher) Get(ctx context.Context, from Pos) <-chan Line {
 				return
 			}
 			if lineNum != POS_UNKNOWN {
-				lineNum++
+				lineNum += LineNo(lines)
 			}
 		}
 	}(from.Line)
@@ -286,94 +372,179 @@ func (f *Fetcher) Get(ctx context.Context, from Pos) <-chan Line {
 }
 
 // Search returns position of next matching search
-func (f *Fetcher) Search(ctx context.Context, from Pos, searchFunc filters.SearchFunc) (pos Pos) {
+func (f *Fetcher) Search(ctx context.Context, from Pos, searchFunc filters.SearchFunc, progress searchProgress) (pos Pos) {
 	defer logging.Timeit("Searching")()
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	reader := f.Get(ctx, from)
-	for l := range reader {
-		if searchFunc(l.Str.Runes) != nil {
-			return l.Pos
-		}
-	}
-	return POS_NOT_FOUND
+	return f.searchParallel(ctx, from, true, func(l Line) bool { return searchFunc(l.Str.Runes) != nil }, progress)
 }
 
 // Search returns position of next matching search
-func (f *Fetcher) SearchHighlighted(ctx context.Context, from Pos) (pos Pos) {
+func (f *Fetcher) SearchHighlighted(ctx context.Context, from Pos, progress searchProgress) (pos Pos) {
 	defer logging.Timeit("Searching")()
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	reader := f.Get(ctx, from)
-	for l := range reader {
-		if l.Highlighted {
-			return l.Pos
-		}
-	}
-	return POS_NOT_FOUND
+	return f.searchParallel(ctx, from, true, func(l Line) bool { return l.Highlighted }, progress)
 }
 
 // SearchBack returns position of next matching back-search
-func (f *Fetcher) SearchBack(ctx context.Context, from Pos, searchFunc filters.SearchFunc) (pos Pos) {
+func (f *Fetcher) SearchBack(ctx context.Context, from Pos, searchFunc filters.SearchFunc, progress searchProgress) (pos Pos) {
 	defer logging.Timeit("Back-Searching")()
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	reader := f.GetBack(ctx, from)
-	for l := range reader {
-		if searchFunc(l.Str.Runes) != nil {
-			return l.Pos
-		}
-	}
-	return POS_NOT_FOUND
+	return f.searchParallel(ctx, from, false, func(l Line) bool { return searchFunc(l.Str.Runes) != nil }, progress)
 }
 
 // SearchBack returns position of next matching back-search
-func (f *Fetcher) SearchBackHighlighted(ctx context.Context, from Pos) (pos Pos) {
+func (f *Fetcher) SearchBackHighlighted(ctx context.Context, from Pos, progress searchProgress) (pos Pos) {
 	defer logging.Timeit("Back-Searching")()
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	reader := f.GetBack(ctx, from)
-	for l := range reader {
-		if l.Highlighted {
-			return l.Pos
+	return f.searchParallel(ctx, from, false, func(l Line) bool { return l.Highlighted }, progress)
+}
+
+// lineStart returns position of given line, or position of the last line if there are less lines in the file
+func (f *Fetcher) lineStart(ctx context.Context, line LineNo) Pos {
+	f.index.waitLine(ctx, line, f.size)
+	if offset, ok := f.index.lineOffset(line); ok && offset < f.size() {
+		return Pos{line, offset}
+	}
+	last := f.index.total() - 1
+	if last < 0 {
+		return Pos{0, 0}
+	}
+	offset, _ := f.index.lineOffset(last)
+	return Pos{last, offset}
+}
+
+// Once search range is that small, it is scanned line by line
+const timeSearchLinear = 64 * 1024
+
+// Lines without timestamp skipped looking for one, before giving up
+const timeSearchMaxLines = 1000
+
+// timeOffset binary-searches offset of the first line with timestamp at or after target,
+// lines are expected to be sorted by time. Returns io.EOF if there is no such line
+func (f *Fetcher) timeOffset(ctx context.Context, target time.Time) (Offset, error) {
+	defer logging.Timeit("Searching time")()
+	var parser timestamps.Parser
+	lo, hi := Offset(0), f.size()
+	for hi-lo > timeSearchLinear {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		mid := lo + (hi-lo)/2
+		if ts, _, ok := f.timestampAfter(mid, &parser, timeSearchMaxLines); !ok || !ts.Before(target) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	for {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		ts, offset, ok := f.timestampAfter(lo, &parser, -1)
+		if !ok {
+			return 0, io.EOF
 		}
+		if !ts.Before(target) {
+			return offset, nil
+		}
+		lo = offset + 1
 	}
-	return POS_NOT_FOUND
 }
 
-func (f *Fetcher) advanceLines(from Pos) PosLine {
+// timestampAfter returns timestamp of the first line having one, starting from the line at or after offset.
+// Gives up after maxLines lines without timestamp, unless maxLines is negative
+func (f *Fetcher) timestampAfter(offset Offset, parser *timestamps.Parser, maxLines int) (time.Time, Offset, bool) {
+	lineOffset, err := f.findLine(offset)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
 	f.lock.Lock()
 	defer f.lock.Unlock()
-	f.seek(from.Offset)
-	i := from.Line
-	ret := PosLine{Pos: from}
-	for {
-		str, offset, err := f.readline()
-		if err == io.EOF && len(str) == 0 {
-			return ret
+	f.seek(lineOffset)
+	for i := 0; maxLines < 0 || i < maxLines; i++ {
+		str, pos, err := f.readline()
+		if len(str) == 0 && err == io.EOF {
+			break
 		}
-		ret.b, ret.Offset, ret.Line = str, offset, i
-		if err == io.EOF {
-			ret.b = ret.b[:len(ret.b)-1] // got no \n stripped, hacky, used only for saving map and it lacks error information
-			return ret
+		if ts, ok := parser.Parse(string(str)); ok {
+			return ts, pos, true
 		}
-		if i >= 3500+from.Line {
-			return ret
+		if err == io.EOF {
+			break
 		}
-		i++
 	}
+	return time.Time{}, 0, false
+}
+
+// isStream returns true if reading from cache file written by slit itself, i.e. stdin
+func (f *Fetcher) isStream() bool {
+	f.mLock.RLock()
+	defer f.mLock.RUnlock()
+	return f.streamDone != nil
+}
+
+// isStreaming returns true if cache file is still being written
+func (f *Fetcher) isStreaming() bool {
+	f.mLock.RLock()
+	streamDone := f.streamDone
+	f.mLock.RUnlock()
+	if streamDone == nil {
+		return false
+	}
+	select {
+	case <-streamDone:
+		return false
+	default:
+		return true
+	}
+}
+
+// endsWithNewline returns true if last line of the file is complete
+func (f *Fetcher) endsWithNewline() bool {
+	b := make([]byte, 1)
+	if _, err := f.reader.ReadAt(b, int64(f.lastOffset())); err != nil {
+		return false
+	}
+	return b[0] == '\n'
 }
 
 func (f *Fetcher) lastOffset() Offset {
+	size := f.size()
+	if size == 0 {
+		return Offset(0)
+	}
+	return size - 1
+}
+
+func (f *Fetcher) size() Offset {
 	stat, err := f.reader.Stat()
 	if err != nil {
 		logging.Debug(fmt.Sprintf("Error retrieving stat from file: %s", err))
 		return Offset(0)
 	}
-	if stat.Size() == 0 {
-		return Offset(0)
-	}
-	return Offset(stat.Size() - 1)
+	return Offset(stat.Size())
+}
+
+// reset drops everything known about the file, used once file was truncated
+func (f *Fetcher) reset() {
+	f.lock.Lock()
+	f.lineReader = nil
+	f.lineReaderOffset = 0
+	f.highlightedLines = nil // not truncated in place, snapshots of the chain might share it
+	f.lock.Unlock()
+	f.index.reset()
+	f.mLock.Lock()
+	f.generation++
+	f.bounds = timeBounds{}
+	f.mLock.Unlock()
+}
+
+func (f *Fetcher) setStreamDone(streamDone <-chan struct{}) {
+	f.mLock.Lock()
+	f.streamDone = streamDone
+	f.mLock.Unlock()
+}
+
+func (f *Fetcher) getGeneration() int {
+	f.mLock.RLock()
+	defer f.mLock.RUnlock()
+	return f.generation
 }
 
 const fetchBackStep = 64 * 1024
@@ -385,17 +556,49 @@ func (f *Fetcher) GetBack(ctx context.Context, fromPos Pos) <-chan Line {
 	var l Line
 	var lineOffset Offset
 	var err error
+	low, high := f.timeBounds(ctx)
+	if fromPos.Offset >= high {
+		fromPos = Pos{POS_UNKNOWN, high - 1}
+	}
 	// Determine if seeking from the end
-	if fromPos.Line == POS_UNKNOWN {
+	if fromPos.Line == POS_UNKNOWN && fromPos.Offset >= 0 {
 		fromPos.Line = f.resolveLine(fromPos.Offset)
 	}
+	f.lock.Lock()
+	lineContext := f.lineContext
+	f.lock.Unlock()
+	var cf *contextFilter
+	if lineContext.enabled() {
+		// lines after may be included, showing the first lines as their context
+		cf = newContextFilter(lineContext, true, fromPos.Offset)
+		var moved int
+		fromPos.Offset, moved = f.linesAfter(fromPos.Offset, lineContext.before)
+		if fromPos.Line >= 0 {
+			fromPos.Line += LineNo(moved)
+		}
+	}
 	lineAssign := fromPos.Line
 	from := fromPos.Offset
+	isStart := f.recordStartFunc(f.readingChain())
+	var record []PosLine // lines of current record, in reverse order
+	send := func(l Line) bool {
+		select {
+		case ret <- f.labeled(l): //TODO: paralellize
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
 	go func(lineAssign LineNo) {
 		//defer f.lock.Unlock()
 		defer close(ret)
 		for {
-			if from < 0 {
+			if from < low {
+				if cf != nil {
+					for _, l := range cf.finish() {
+						send(l)
+					}
+				}
 				return
 			}
 			tmpLines = tmpLines[:0]
@@ -415,25 +618,43 @@ func (f *Fetcher) GetBack(ctx context.Context, fromPos Pos) <-chan Line {
 				if pos > from {
 					break
 				}
-				tmpLines = append(tmpLines, PosLine{str, Pos{POS_UNKNOWN, pos}})
+				if pos >= low {
+					tmpLines = append(tmpLines, PosLine{str, Pos{POS_UNKNOWN, pos}})
+				}
 				// Ignoring context cancel here, reading is fast enough to exclude expensive channel checks
 				if err == io.EOF {
 					break
 				}
 			}
 			for i := len(tmpLines) - 1; i >= 0; i-- {
-				if fromPos.Line > 0 {
+				if fromPos.Line >= 0 {
 					tmpLines[i].Line = lineAssign
 					lineAssign--
 					//logging.Debug("assigned line", tmpLines[i].Line)
 				}
-				l = f.filteredLine(tmpLines[i])
+				posLine := tmpLines[i]
+				if isStart != nil {
+					record = append(record, posLine)
+					if posLine.Offset != low && len(record) < recordMaxLines && !isStart(posLine.b) {
+						continue
+					}
+					posLine = joinRecord(record)
+					record = record[:0]
+				}
+				l = f.filteredLine(posLine)
+				if cf != nil {
+					for _, l := range cf.push(posLine, l) {
+						if !send(l) {
+							f.lock.Unlock()
+							return
+						}
+					}
+					continue
+				}
 				if l.Pos.Line == POS_FILTERED_OUT { //filtered out
 					continue
 				}
-				select {
-				case ret <- l: //TODO: paralellize
-				case <-ctx.Done():
+				if !send(l) {
 					f.lock.Unlock()
 					return
 				}
@@ -445,69 +666,62 @@ func (f *Fetcher) GetBack(ctx context.Context, fromPos Pos) <-chan Line {
 	return ret
 }
 
-func (f *Fetcher) gcMap(ctx context.Context) {
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(100 * time.Millisecond):
-			f.mLock.RLock()
-			size := len(f.lineMap)
-			f.mLock.RUnlock()
-			if size < 1000 {
-				continue
-			}
-			f.mLock.Lock()
-			keys := make(offsetArr, len(f.lineMap))
-
-			i := 0
-			for k := range f.lineMap {
-				keys[i] = k
-				i++
-			}
-			sort.Sort(keys)
-			toDelete := keys[:len(keys)-1000]
-			for _, k := range toDelete {
-				delete(f.lineMap, k)
-			}
-			f.mLock.Unlock()
-		}
+// resolveLine returns number of the line containing offset, POS_UNKNOWN if it is not indexed yet
+func (f *Fetcher) resolveLine(o Offset) LineNo {
+	if lineNum, ok := f.index.lineAt(o); ok {
+		return lineNum
 	}
+	return POS_UNKNOWN
 }
 
-func (f *Fetcher) updateMap(pos PosLine) {
-	f.mLock.Lock()
-	f.lineMap[pos.Offset+Offset(len(pos.b))] = pos.Line
-	f.lineMap[pos.Offset] = pos.Line
-	f.mLock.Unlock()
+// setFilters replaces filters chain, chain given is not modified afterwards
+func (f *Fetcher) setFilters(chain []*filters.Filter) {
+	f.lock.Lock()
+	f.filters = chain
+	f.lock.Unlock()
 }
 
-func (f *Fetcher) resolveLine(o Offset) LineNo {
-	f.mLock.Lock()
-	defer f.mLock.Unlock()
-	if lineNum, ok := f.lineMap[o]; ok {
-		return lineNum
-	}
-	return POS_UNKNOWN
+// addFilter appends filter to the chain and enables filters
+func (f *Fetcher) addFilter(filter *filters.Filter) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.filters = append(f.filters[:len(f.filters):len(f.filters)], filter) // copied, chain might be in use
+	f.filtersEnabled = true
 }
 
 func (f *Fetcher) removeLastFilter() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
 	if len(f.filters) > 0 {
 		f.filters = f.filters[:len(f.filters)-1]
 		return true
 	}
 	return false
 }
+
+func (f *Fetcher) switchFilters() {
+	f.lock.Lock()
+	f.filtersEnabled = !f.filtersEnabled
+	f.lock.Unlock()
+}
+
 func (f *Fetcher) toggleHighlight(line LineNo) {
-	for i, highlighted := range f.highlightedLines {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	highlightedLines := make([]LineNo, 0, len(f.highlightedLines)+1)
+	found := false
+	for _, highlighted := range f.highlightedLines {
 		if highlighted == line {
-			copy(f.highlightedLines[i:], f.highlightedLines[i+1:])
-			f.highlightedLines = f.highlightedLines[:len(f.highlightedLines)-1]
-			return
+			found = true
+			continue
 		}
+		highlightedLines = append(highlightedLines, highlighted)
+	}
+	if !found {
+		highlightedLines = append(highlightedLines, line)
+		sort.Slice(highlightedLines, func(i, j int) bool {
+			return highlightedLines[i] < highlightedLines[j]
+		})
 	}
-	f.highlightedLines = append(f.highlightedLines, line)
-	sort.Slice(f.highlightedLines, func(i, j int) bool {
-		return f.highlightedLines[i] < f.highlightedLines[j]
-	})
+	f.highlightedLines = highlightedLines
 }
@@ -0,0 +1,203 @@
+package slit
+
+import (
+	"fmt"
+
+	"github.com/nsf/termbox-go"
+	"github.com/tigrawap/slit/filters"
+)
+
+const filterManagerHelp = "Filters: Space toggle, J/K move, e edit, d delete, Esc close"
+
+// filterManager is a panel listing filters chain of the viewer. Filters can be toggled, reordered, edited or deleted,
+// view is re-filtered on every change
+type filterManager struct {
+	v        *viewer
+	selected int
+}
+
+var filterActionModes = map[filters.FilterAction]infobarMode{
+	filters.FilterIntersect: ibModeFilter,
+	filters.FilterUnion:     ibModeAppend,
+	filters.FilterExclude:   ibModeExclude,
+	filters.FilterHighlight: ibModeHighlight,
+}
+
+func (m *filterManager) chain() []*filters.Filter {
+	return append([]*filters.Filter(nil), m.v.fetcher.snapshotChain().filters...)
+}
+
+func (m *filterManager) processKey(ev termbox.Event) (a action) {
+	v := m.v
+	chain := m.chain()
+	changed := false
+	if ev.Ch != 0 {
+		switch ev.Ch {
+		case 'j':
+			m.move(+1)
+		case 'k':
+			m.move(-1)
+		case 'J':
+			changed = m.swap(chain, +1)
+		case 'K':
+			changed = m.swap(chain, -1)
+		case 'd':
+			if m.selected < len(chain) {
+				chain = append(chain[:m.selected], chain[m.selected+1:]...)
+				changed = true
+			}
+		case 'e':
+			m.edit(chain)
+			return
+		case 'q', 'F':
+			return m.close()
+		}
+	} else {
+		switch ev.Key {
+		case termbox.KeyArrowDown:
+			m.move(+1)
+		case termbox.KeyArrowUp:
+			m.move(-1)
+		case termbox.KeySpace:
+			if m.selected < len(chain) {
+				chain[m.selected] = chain[m.selected].Toggled()
+				changed = true
+			}
+		case termbox.KeyEnter:
+			m.edit(chain)
+			return
+		case termbox.KeyDelete:
+			if m.selected < len(chain) {
+				chain = append(chain[:m.selected], chain[m.selected+1:]...)
+				changed = true
+			}
+		case termbox.KeyEsc:
+			return m.close()
+		}
+	}
+	if changed {
+		v.fetcher.setFilters(chain)
+		v.onFiltersChange()
+	}
+	m.clampSelection()
+	v.draw()
+	return
+}
+
+func (m *filterManager) open() {
+	m.v.focus = m
+	m.clampSelection()
+	m.v.draw()
+}
+
+func (m *filterManager) close() action {
+	m.v.focus = m.v
+	m.v.draw()
+	return ACTION_RESET_FOCUS
+}
+
+func (m *filterManager) move(direction int) {
+	m.selected += direction
+	m.clampSelection()
+}
+
+func (m *filterManager) clampSelection() {
+	if count := len(m.v.fetcher.snapshotChain().filters); m.selected >= count {
+		m.selected = count - 1
+	}
+	if m.selected < 0 {
+		m.selected = 0
+	}
+}
+
+// swap moves selected filter up or down the chain
+func (m *filterManager) swap(chain []*filters.Filter, direction int) bool {
+	target := m.selected + direction
+	if m.selected >= len(chain) || target < 0 || target >= len(chain) {
+		return false
+	}
+	chain[m.selected], chain[target] = chain[target], chain[m.selected]
+	m.selected = target
+	return true
+}
+
+// edit opens filter prompt with pattern of selected filter, which is replaced once input is submitted
+func (m *filterManager) edit(chain []*filters.Filter) {
+	if m.selected >= len(chain) {
+		return
+	}
+	v := m.v
+	filter := chain[m.selected]
+	v.editedFilter = m.selected
+	v.focus = &v.info
+	v.info.searchType = filter.SearchType()
+	v.info.reset(filterActionModes[filter.Action])
+	v.info.setInput(string(filter.Sub()))
+}
+
+func (m *filterManager) draw() {
+	chain := m.v.fetcher.snapshotChain().filters
+	rows := make([]panelRow, len(chain))
+	for i, filter := range chain {
+		state := "x"
+		fg := filter.SearchType().Color
+		if filter.Disabled {
+			state = " "
+			fg = termbox.ColorDefault
+		}
+		rows[i] = panelRow{
+			text: fmt.Sprintf("[%s] %c %-5s %s", state, filter.Sign(), filter.SearchType().Name, string(filter.Sub())),
+			fg:   fg,
+		}
+	}
+	drawPanel(m.v, filterManagerHelp, "No filters", rows, m.selected)
+}
+
+type panelRow struct {
+	text string
+	fg   termbox.Attribute
+}
+
+// drawPanel shows list of rows at the bottom of the screen, above infobar, keeping selected row visible
+func drawPanel(v *viewer, header, empty string, rows []panelRow, selected int) {
+	height := len(rows) + 1
+	if len(rows) == 0 {
+		height++
+	}
+	if height > v.height {
+		height = v.height
+	}
+	top := v.height - height
+	first := 0 // first visible row
+	if selected >= height-1 {
+		first = selected - height + 2
+	}
+	drawPanelRow(v, top, header, termbox.ColorDefault|termbox.AttrBold, termbox.ColorBlue)
+	if len(rows) == 0 {
+		drawPanelRow(v, top+1, "  "+empty, termbox.ColorDefault, termbox.ColorDefault)
+		return
+	}
+	for i := first; i < len(rows) && top+1+i-first < v.height; i++ {
+		cursor := "  "
+		fg := rows[i].fg
+		if i == selected {
+			cursor = "> "
+			fg |= termbox.AttrReverse
+		}
+		drawPanelRow(v, top+1+i-first, cursor+rows[i].text, fg, termbox.ColorDefault)
+	}
+}
+
+func drawPanelRow(v *viewer, y int, text string, fg, bg termbox.Attribute) {
+	x := 0
+	for _, ch := range text {
+		if x >= v.width {
+			return
+		}
+		termbox.SetCell(x, y, ch, fg, bg)
+		x++
+	}
+	for ; x < v.width; x++ {
+		termbox.SetCell(x, y, ' ', fg, bg)
+	}
+}
@@ -2,28 +2,87 @@ package filters
 
 import "fmt"
 
+// location formats place in filters file error happened at, empty for inline filters
+func location(filename string, line int) string {
+	if filename == "" {
+		return ""
+	}
+	if line == 0 {
+		return fmt.Sprintf(" in \"%s\"", filename)
+	}
+	return fmt.Sprintf(" in \"%s\", line %d", filename, line)
+}
+
 type UnknownFilterTypeError struct {
 	FilterTypeStr string
 	Filename      string
+	Line          int
 }
 
 func (e *UnknownFilterTypeError) Error() string {
-	outMsg := fmt.Sprintf("Unknown filter type \"%s\"", e.FilterTypeStr)
-	if e.Filename != "" {
-		outMsg += fmt.Sprintf(" in \"%s\"", e.Filename)
-	}
-	return outMsg
+	return fmt.Sprintf("Unknown filter type \"%s\"", e.FilterTypeStr) + location(e.Filename, e.Line)
+}
+
+type UnknownColorError struct {
+	ColorStr string
+	Filename string
+	Line     int
+}
+
+func (e *UnknownColorError) Error() string {
+	return fmt.Sprintf("Unknown color \"%s\"", e.ColorStr) + location(e.Filename, e.Line)
 }
 
 type FilterTooShortError struct {
 	FilterStr string
 	Filename  string
+	Line      int
 }
 
 func (e *FilterTooShortError) Error() string {
-	outMsg := fmt.Sprintf("Filter \"%s\" is too short", e.FilterStr)
-	if e.Filename != "" {
-		outMsg += fmt.Sprintf(" in \"%s\"", e.Filename)
-	}
-	return outMsg
+	return fmt.Sprintf("Filter \"%s\" is too short", e.FilterStr) + location(e.Filename, e.Line)
+}
+
+type IncludeCycleError struct {
+	Include  string
+	Filename string
+	Line     int
+}
+
+func (e *IncludeCycleError) Error() string {
+	return fmt.Sprintf("Filters file \"%s\" is already included", e.Include) + location(e.Filename, e.Line)
+}
+
+type ExpressionError struct {
+	Expression string
+	Position   int
+	Reason     string
+	Filename   string
+	Line       int
+}
+
+func (e *ExpressionError) Error() string {
+	return fmt.Sprintf("Bad expression \"%s\" at %d: %s", e.Expression, e.Position+1, e.Reason) + location(e.Filename, e.Line)
+}
+
+type FieldFilterError struct {
+	Filter   string
+	Filename string
+	Line     int
+}
+
+func (e *FieldFilterError) Error() string {
+	return fmt.Sprintf("Bad field filter \"%s\", should be KEY or /REGEX/, followed by =, !=, ~, !~, >, >=, <, <= and value", e.Filter) +
+		location(e.Filename, e.Line)
+}
+
+type TimeRangeError struct {
+	Range    string
+	Filename string
+	Line     int
+}
+
+func (e *TimeRangeError) Error() string {
+	return fmt.Sprintf("Bad time range \"%s\", should be SINCE..UNTIL, i.e. 14:00..14:10 or 2019-03-01 14:00..", e.Range) +
+		location(e.Filename, e.Line)
 }
@@ -0,0 +1,273 @@
+package filters
+
+import (
+	"regexp"
+	"unicode"
+)
+
+// Expression grammar, operators are case-sensitive, so lowercase "and" is searched as a word:
+//
+//	or      := and {"OR" and}
+//	and     := not {["AND"] not}     adjacent terms are joined by AND
+//	not     := "NOT" not | primary
+//	primary := "(" or ")" | "quoted literal" | /regex/ | word
+type exprNode interface {
+	// eval returns whether str matches and range of the first matched term, nil range if there is no such
+	eval(str []rune) (bool, []int)
+}
+
+type exprTerm struct {
+	search SearchFunc
+}
+
+type exprNot struct {
+	node exprNode
+}
+
+type exprAnd struct {
+	nodes []exprNode
+}
+
+type exprOr struct {
+	nodes []exprNode
+}
+
+func (e *exprTerm) eval(str []rune) (bool, []int) {
+	r := e.search(str)
+	return r != nil, r
+}
+
+func (e *exprNot) eval(str []rune) (bool, []int) {
+	matched, _ := e.node.eval(str)
+	return !matched, nil
+}
+
+func (e *exprAnd) eval(str []rune) (bool, []int) {
+	var first []int
+	for _, node := range e.nodes {
+		matched, r := node.eval(str)
+		if !matched {
+			return false, nil
+		}
+		if first == nil {
+			first = r
+		}
+	}
+	return true, first
+}
+
+func (e *exprOr) eval(str []rune) (bool, []int) {
+	for _, node := range e.nodes {
+		if matched, r := node.eval(str); matched {
+			return true, r
+		}
+	}
+	return false, nil
+}
+
+type exprTokenKind uint8
+
+const (
+	tokenWord exprTokenKind = iota
+	tokenLiteral
+	tokenRegex
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenOpen
+	tokenClose
+)
+
+type exprToken struct {
+	kind  exprTokenKind
+	value []rune
+	pos   int
+}
+
+func tokenizeExpression(expr []rune) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(expr); {
+		switch c := expr[i]; {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, exprToken{tokenOpen, expr[i : i+1], i})
+			i++
+		case c == ')':
+			tokens = append(tokens, exprToken{tokenClose, expr[i : i+1], i})
+			i++
+		case c == '"' || c == '/':
+			kind := tokenLiteral
+			if c == '/' {
+				kind = tokenRegex
+			}
+			start := i
+			var value []rune
+			for i++; i < len(expr) && expr[i] != c; i++ {
+				if expr[i] == '\\' && i+1 < len(expr) && expr[i+1] == c {
+					i++
+				}
+				value = append(value, expr[i])
+			}
+			if i == len(expr) {
+				return nil, &ExpressionError{Expression: string(expr), Position: start, Reason: "unterminated " + string(c)}
+			}
+			i++
+			tokens = append(tokens, exprToken{kind, value, start})
+		default:
+			start := i
+			for i < len(expr) && !unicode.IsSpace(expr[i]) && expr[i] != '(' && expr[i] != ')' {
+				i++
+			}
+			word := expr[start:i]
+			kind := tokenWord
+			switch string(word) {
+			case "AND":
+				kind = tokenAnd
+			case "OR":
+				kind = tokenOr
+			case "NOT":
+				kind = tokenNot
+			}
+			tokens = append(tokens, exprToken{kind, word, start})
+		}
+	}
+	return tokens, nil
+}
+
+type exprParser struct {
+	expr   []rune
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() *exprToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *exprParser) errorAt(t *exprToken, reason string) error {
+	pos := len(p.expr)
+	if t != nil {
+		pos = t.pos
+	}
+	return &ExpressionError{Expression: string(p.expr), Position: pos, Reason: reason}
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	or := &exprOr{[]exprNode{node}}
+	for t := p.peek(); t != nil && t.kind == tokenOr; t = p.peek() {
+		p.pos++
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or.nodes = append(or.nodes, node)
+	}
+	if len(or.nodes) == 1 {
+		return node, nil
+	}
+	return or, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	node, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	and := &exprAnd{[]exprNode{node}}
+	for t := p.peek(); t != nil && t.kind != tokenOr && t.kind != tokenClose; t = p.peek() {
+		if t.kind == tokenAnd {
+			p.pos++
+		}
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		and.nodes = append(and.nodes, node)
+	}
+	if len(and.nodes) == 1 {
+		return node, nil
+	}
+	return and, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if t := p.peek(); t != nil && t.kind == tokenNot {
+		p.pos++
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNot{node}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, p.errorAt(t, "unexpected end of expression")
+	}
+	p.pos++
+	switch t.kind {
+	case tokenOpen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.peek(); closing == nil || closing.kind != tokenClose {
+			return nil, p.errorAt(closing, "expected )")
+		}
+		p.pos++
+		return node, nil
+	case tokenWord, tokenLiteral:
+		if len(t.value) == 0 {
+			return nil, p.errorAt(t, "empty literal")
+		}
+		search, _ := GetSearchFunc(CaseSensitive, t.value)
+		return &exprTerm{search}, nil
+	case tokenRegex:
+		re, err := regexp.Compile(string(t.value))
+		if err != nil {
+			return nil, p.errorAt(t, "bad regex: "+err.Error())
+		}
+		return &exprTerm{func(str []rune) []int {
+			return re.FindStringIndex(string(str))
+		}}, nil
+	}
+	return nil, p.errorAt(t, "unexpected "+string(t.value))
+}
+
+// compileExpression returns SearchFunc matching whole boolean expression.
+// Returned range is the one of first matched term, whole string if match is only due to negations
+func compileExpression(expr []rune) (SearchFunc, error) {
+	tokens, err := tokenizeExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{expr: expr, tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != nil {
+		return nil, p.errorAt(t, "unexpected )")
+	}
+	return func(str []rune) []int {
+		matched, r := node.eval(str)
+		if !matched {
+			return nil
+		}
+		if r == nil {
+			return []int{0, len(str)}
+		}
+		return r
+	}, nil
+}
@@ -0,0 +1,63 @@
+package filters
+
+import "testing"
+
+func TestExpression(t *testing.T) {
+	tests := []struct {
+		expr  string
+		line  string
+		match []int
+	}{
+		{"ERROR", "ERROR timeout", []int{0, 5}},
+		{"ERROR timeout", "ERROR timeout", []int{0, 5}},
+		{"ERROR AND timeout", "ERROR refused", nil},
+		{"timeout OR refused", "ERROR refused", []int{6, 13}},
+		{"(DEBUG OR INFO) AND NOT (send OR \"pipe closed\")", "INFO started", []int{0, 4}},
+		{"(DEBUG OR INFO) AND NOT (send OR \"pipe closed\")", "INFO pipe closed", nil},
+		{"(DEBUG OR INFO) AND NOT (send OR \"pipe closed\")", "WARN started", nil},
+		{"NOT DEBUG", "INFO started", []int{0, 12}},
+		{"NOT NOT DEBUG", "DEBUG x", []int{0, 7}},
+		{"a OR b AND c", "a", []int{0, 1}},
+		{"a OR b AND c", "b", nil},
+		{"and", "this and that", []int{5, 8}},
+		{"/took [0-9]+ms/", "request took 20ms", []int{8, 17}},
+		{`"say \"hi\""`, `he said: say "hi"`, []int{9, 17}},
+		{`/a\/b/`, "path a/b", []int{5, 8}},
+	}
+	for _, test := range tests {
+		search, err := compileExpression([]rune(test.expr))
+		if err != nil {
+			t.Fatalf("%q: %v", test.expr, err)
+		}
+		if got := search([]rune(test.line)); !equalRange(got, test.match) {
+			t.Errorf("%q on %q: got %v, expected %v", test.expr, test.line, got, test.match)
+		}
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	tests := []struct {
+		expr     string
+		position int
+	}{
+		{"", 0},
+		{"(a OR b", 7},
+		{"a OR b)", 6},
+		{"a AND", 5},
+		{"NOT", 3},
+		{"\"unterminated", 0},
+		{"a /bad(/", 2},
+		{"OR a", 0},
+	}
+	for _, test := range tests {
+		_, err := compileExpression([]rune(test.expr))
+		exprErr, ok := err.(*ExpressionError)
+		if !ok {
+			t.Errorf("%q: expected expression error, got %v", test.expr, err)
+			continue
+		}
+		if exprErr.Position != test.position {
+			t.Errorf("%q: error at %d, expected at %d: %v", test.expr, exprErr.Position, test.position, err)
+		}
+	}
+}
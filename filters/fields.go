@@ -0,0 +1,203 @@
+package filters
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"code.cloudfoundry.org/bytefmt"
+	"github.com/tigrawap/slit/structured"
+)
+
+// fieldOperators are checked in order, so two-character operators go before their prefixes
+var fieldOperators = []string{">=", "<=", "!=", "!~", "=", "~", ">", "<"}
+
+// fieldCondition is a parsed field filter, i.e. level=error, path!~^/health or /took (\S+)/>500ms
+type fieldCondition struct {
+	path     string         // name of the field, empty if value is captured by re
+	re       *regexp.Regexp // value is the first capture group, or the whole match if there are no groups
+	operator string
+	operand  string
+}
+
+func parseFieldCondition(sub []rune) (fieldCondition, error) {
+	str := string(sub)
+	var cond fieldCondition
+	from := 0
+	if strings.HasPrefix(str, "/") {
+		end := closingSlash(str)
+		if end == -1 {
+			return cond, &FieldFilterError{Filter: str}
+		}
+		re, err := regexp.Compile(strings.Replace(str[1:end], `\/`, "/", -1))
+		if err != nil {
+			return cond, ErrBadFilterDefinition
+		}
+		cond.re = re
+		from = end + 1
+	}
+	for i, r := range str[from:] {
+		i += from
+		if !strings.ContainsRune("=~!<>", r) {
+			continue
+		}
+		for _, op := range fieldOperators {
+			if !strings.HasPrefix(str[i:], op) {
+				continue
+			}
+			if cond.re == nil {
+				cond.path = strings.TrimSpace(str[:i])
+				if cond.path == "" || strings.IndexFunc(cond.path, unicode.IsSpace) != -1 {
+					return cond, &FieldFilterError{Filter: str}
+				}
+			} else if strings.TrimSpace(str[from:i]) != "" {
+				return cond, &FieldFilterError{Filter: str}
+			}
+			cond.operator, cond.operand = op, str[i+len(op):]
+			return cond, nil
+		}
+	}
+	return cond, &FieldFilterError{Filter: str}
+}
+
+// closingSlash returns index of slash closing regex started by the first character, -1 if it is not closed
+func closingSlash(str string) int {
+	for i := 1; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			i++
+		case '/':
+			return i
+		}
+	}
+	return -1
+}
+
+// compileField returns search func matching lines by value of the named field of JSON objects or logfmt pairs,
+// or by value captured by regex. Lines without the value never match
+func compileField(sub []rune) (SearchFunc, error) {
+	cond, err := parseFieldCondition(sub)
+	if err != nil {
+		return nil, err
+	}
+	match, err := compileComparison(cond.operator, cond.operand)
+	if e, ok := err.(*FieldFilterError); ok {
+		e.Filter = string(sub)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cond.re != nil {
+		return func(str []rune) []int {
+			line := string(str)
+			m := cond.re.FindStringSubmatchIndex(line)
+			if m == nil {
+				return nil
+			}
+			if len(m) > 2 && m[2] != -1 {
+				m = m[2:4]
+			}
+			if !match(line[m[0]:m[1]]) {
+				return nil
+			}
+			if m[0] == m[1] { // empty range can't be highlighted
+				return []int{0, len(str)}
+			}
+			start := utf8.RuneCountInString(line[:m[0]])
+			return []int{start, start + utf8.RuneCountInString(line[m[0]:m[1]])}
+		}, nil
+	}
+	return func(str []rune) []int {
+		line := string(str)
+		record, ok := structured.ParseFields(line)
+		if !ok {
+			return nil
+		}
+		f, ok := record.Lookup(cond.path)
+		if !ok || !match(f.Value) {
+			return nil
+		}
+		return valueRange(line, f)
+	}, nil
+}
+
+// compileComparison returns func comparing value with operand. Equality and regexes compare text,
+// ordering operators compare numbers, durations (500ms, 1m30s) or byte sizes (10K, 1.5GB) depending on operand
+func compileComparison(operator, operand string) (func(value string) bool, error) {
+	switch operator {
+	case "=":
+		return func(value string) bool { return value == operand }, nil
+	case "!=":
+		return func(value string) bool { return value != operand }, nil
+	case "~", "!~":
+		re, err := regexp.Compile(operand)
+		if err != nil {
+			return nil, ErrBadFilterDefinition
+		}
+		negate := operator == "!~"
+		return func(value string) bool { return re.MatchString(value) != negate }, nil
+	}
+	parse, limit, ok := quantityParser(strings.TrimSpace(operand))
+	if !ok {
+		return nil, &FieldFilterError{}
+	}
+	var compare func(float64) bool
+	switch operator {
+	case ">":
+		compare = func(v float64) bool { return v > limit }
+	case ">=":
+		compare = func(v float64) bool { return v >= limit }
+	case "<":
+		compare = func(v float64) bool { return v < limit }
+	case "<=":
+		compare = func(v float64) bool { return v <= limit }
+	}
+	return func(value string) bool {
+		v, ok := parse(strings.TrimSpace(value))
+		return ok && compare(v)
+	}, nil
+}
+
+// quantityParser returns parser of values of the same kind as operand and parsed operand.
+// Durations go before sizes, so 10m is 10 minutes, while 10M and 10mb are 10 megabytes
+func quantityParser(operand string) (func(string) (float64, bool), float64, bool) {
+	for _, parse := range []func(string) (float64, bool){parseNumber, parseDuration, parseSize} {
+		if limit, ok := parse(operand); ok {
+			return parse, limit, true
+		}
+	}
+	return nil, 0, false
+}
+
+func parseNumber(str string) (float64, bool) {
+	v, err := strconv.ParseFloat(str, 64)
+	return v, err == nil
+}
+
+func parseDuration(str string) (float64, bool) {
+	d, err := time.ParseDuration(str)
+	return float64(d), err == nil
+}
+
+// parseSize parses byte size with case-insensitive unit, i.e. 10K or 1.5gb, number without unit is number of bytes
+func parseSize(str string) (float64, bool) {
+	if v, ok := parseNumber(str); ok {
+		return v, true
+	}
+	v, err := bytefmt.ToBytes(str)
+	return float64(v), err == nil
+}
+
+// valueRange returns range of runes of the field value in the line, so it is highlighted by search.
+// Whole line if value is empty
+func valueRange(line string, f structured.Field) []int {
+	start, end := f.Span()
+	if start >= end {
+		return []int{0, utf8.RuneCountInString(line)}
+	}
+	from := utf8.RuneCountInString(line[:start])
+	return []int{from, from + utf8.RuneCountInString(line[start:end])}
+}
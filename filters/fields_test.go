@@ -0,0 +1,102 @@
+package filters
+
+import "testing"
+
+func TestFieldFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		line   string
+		match  []int
+	}{
+		{"level=error", `{"msg":"level error here","level":"error"}`, []int{35, 40}},
+		{"level=error", `{"msg":"no error","level":"info"}`, nil},
+		{"level=error", `msg="level=x" level=error`, []int{20, 25}},
+		{"msg=level=x", `msg="level=x" level=error`, []int{5, 12}},
+		{"user.id=42", `{"id":42,"user":{ "id" : 42}}`, []int{25, 27}},
+		{"user.name=ё", `{"msg":"ёёё","user":{"name":"ё"}}`, []int{29, 30}},
+		{"path!~^/health", `path=/health/live status=200`, nil},
+		{"path!~^/health", `path=/api status=200`, []int{5, 9}},
+		{"status>=500", `status=503`, []int{7, 10}},
+		{"status>=500", `status=404`, nil},
+		{"took>1m", `took=2m`, []int{5, 7}},
+		{"took>1m", `took=30s`, nil},
+		{"size>10m", `size=5m`, nil},
+		{"size>10M", `size=12MB`, []int{5, 9}},
+		{"empty=", `{"empty":""}`, []int{0, 12}},
+		{"level=error", `plain level=error text`, []int{12, 17}},
+		{"level=error", `plain text`, nil},
+		{`/took (\S+)/>500ms`, `request took 1.5s`, []int{13, 17}},
+		{`/took (\S+)/>500ms`, `request took 20ms`, nil},
+	}
+	for _, test := range tests {
+		search, err := compileField([]rune(test.filter))
+		if err != nil {
+			t.Fatalf("%q: %v", test.filter, err)
+		}
+		if got := search([]rune(test.line)); !equalRange(got, test.match) {
+			t.Errorf("%q on %q: got %v, expected %v", test.filter, test.line, got, test.match)
+		}
+	}
+}
+
+func TestFieldFilterErrors(t *testing.T) {
+	for _, filter := range []string{"level", "=error", "two words=x", "/unclosed=x", "/(/=x", "status>abc", "path~("} {
+		if _, err := compileField([]rune(filter)); err == nil {
+			t.Errorf("%q: expected error", filter)
+		}
+	}
+}
+
+func equalRange(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQuantityParser(t *testing.T) {
+	tests := []struct {
+		operand string
+		value   string
+		limit   float64
+	}{
+		{"500", "500", 500},
+		{"1.5", "1.5", 1.5},
+		{"500ms", "0.5s", 5e8},
+		{"10m0s", "10m", 6e11},
+		{"1m30s", "90s", 9e10},
+		{"1m", "60s", 6e10},
+		{"10m", "600000ms", 6e11},
+		{"2h", "120m", 7.2e12},
+		{"5us", "5000ns", 5e3},
+		{"10M", "10mb", 10 << 20},
+		{"10mb", "10M", 10 << 20},
+		{"10MiB", "10m", 10 << 20},
+		{"1g", "1G", 1 << 30},
+		{"1.5GB", "1536mib", 1.5 * (1 << 30)},
+		{"2k", "2048", 2048},
+	}
+	for _, test := range tests {
+		parse, limit, ok := quantityParser(test.operand)
+		if !ok {
+			t.Errorf("%q: not parsed", test.operand)
+			continue
+		}
+		if limit != test.limit {
+			t.Errorf("%q: got %v, expected %v", test.operand, limit, test.limit)
+		}
+		if v, ok := parse(test.value); !ok || v != test.limit {
+			t.Errorf("%q: value %q parsed as %v %v, expected %v", test.operand, test.value, v, ok, test.limit)
+		}
+	}
+	for _, operand := range []string{"", "abc", "10x", "ms"} {
+		if _, _, ok := quantityParser(operand); ok {
+			t.Errorf("%q: expected to fail", operand)
+		}
+	}
+}
@@ -3,10 +3,12 @@ package filters
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"github.com/nsf/termbox-go"
 	"github.com/tigrawap/slit/runes"
 	"github.com/tigrawap/slit/utils"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"unicode"
@@ -42,6 +44,36 @@ var RegEx = SearchType{
 	Color: termbox.ColorRed,
 	Name:  "RegEx",
 }
+
+// Expression is a boolean expression of terms, i.e. (DEBUG OR INFO) AND NOT (send OR "pipe closed")
+var Expression = SearchType{
+	Color: termbox.ColorCyan,
+	Name:  "Expr",
+}
+
+// CaseInsensitive compares characters under Unicode case folding
+var CaseInsensitive = SearchType{
+	Color: termbox.ColorGreen,
+	Name:  "CaseI",
+}
+
+// SmartCase is case-insensitive, unless pattern contains uppercase characters
+var SmartCase = SearchType{
+	Color: termbox.ColorMagenta,
+	Name:  "Smart",
+}
+
+// Field matches value of the named field of JSON or logfmt line, i.e. level=error or path!~^/health
+var Field = SearchType{
+	Color: termbox.ColorBlue,
+	Name:  "Field",
+}
+
+// Time keeps lines with timestamp within the range, i.e. 14:00..14:10, see ParseTimeRange
+var Time = SearchType{
+	Color: termbox.ColorWhite,
+	Name:  "Time",
+}
 var SearchTypeMap map[uint8]SearchType
 
 type FilterAction uint8
@@ -60,6 +92,27 @@ const (
 	FilterHighlightChar rune = '~'
 )
 
+// highlightColors are names accepted by color modifier of filters file, i.e. ~{color=red}WARN
+var highlightColors = map[string]termbox.Attribute{
+	"black":   termbox.ColorBlack,
+	"red":     termbox.ColorRed,
+	"green":   termbox.ColorGreen,
+	"yellow":  termbox.ColorYellow,
+	"blue":    termbox.ColorBlue,
+	"magenta": termbox.ColorMagenta,
+	"cyan":    termbox.ColorCyan,
+	"white":   termbox.ColorWhite,
+}
+
+// searchTypeAliases are short names of search types in filters file, in addition to full ones
+var searchTypeAliases = map[string]*SearchType{
+	"i": &CaseInsensitive,
+	"f": &Field,
+	"t": &Time,
+}
+
+const includeDirective = "include"
+
 var FilterActionMap = map[rune]FilterAction{
 	FilterIntersectChar: FilterIntersect,
 	FilterUnionChar:     FilterUnion,
@@ -70,7 +123,7 @@ var FilterActionMap = map[rune]FilterAction{
 func init() {
 	SearchTypeMap = make(map[uint8]SearchType)
 	// Should maintain order, otherwise history will be corrupted.
-	for i, r := range []*SearchType{&CaseSensitive, &RegEx} {
+	for i, r := range []*SearchType{&CaseSensitive, &RegEx, &Expression, &CaseInsensitive, &SmartCase, &Field, &Time} {
 		r.ID = uint8(i)
 		SearchTypeMap[r.ID] = *r
 	}
@@ -87,12 +140,68 @@ type Filter struct {
 	st         SearchType
 	Action     FilterAction
 	TakeAction ActionFunc
+	Disabled   bool              // disabled filter is kept in chain, but takes no action
+	Color      termbox.Attribute // background of lines highlighted by ~ filter, default highlight if not set
+	timeRange  *TimeRange        // set for Time filters
+}
+
+func (f *Filter) Sub() []rune { return f.sub }
+
+func (f *Filter) SearchType() SearchType { return f.st }
+
+// TimeRange returns range of Time filter, false for other search types
+func (f *Filter) TimeRange() (TimeRange, bool) {
+	if f.timeRange == nil {
+		return TimeRange{}, false
+	}
+	return *f.timeRange, true
+}
+
+// Sign returns character filter action is typed with, i.e. & for intersect
+func (f *Filter) Sign() rune {
+	for sign, action := range FilterActionMap {
+		if action == f.Action {
+			return sign
+		}
+	}
+	return '?'
+}
+
+// String returns filter in filters file syntax, parsing it gives the same filter
+func (f *Filter) String() string {
+	var mods []string
+	if f.st != CaseSensitive {
+		mods = append(mods, f.st.Name)
+	}
+	if f.Color != termbox.ColorDefault {
+		for name, color := range highlightColors {
+			if color == f.Color {
+				mods = append(mods, "color="+name)
+			}
+		}
+	}
+	str := string(f.Sign())
+	if len(mods) != 0 {
+		str += "{" + strings.Join(mods, ",") + "}"
+	}
+	if sub, _, err := parseModifiers(f.sub); err != nil || len(sub) != len(f.sub) {
+		str += "{}"
+	}
+	return str + string(f.sub)
+}
+
+// Toggled returns copy of the filter, disabled if filter is enabled and vice versa.
+// Filters are never modified in place, since chain might be read concurrently
+func (f *Filter) Toggled() *Filter {
+	toggled := *f
+	toggled.Disabled = !f.Disabled
+	return &toggled
 }
 
 var ErrBadFilterDefinition = errors.New("Bad filter definition")
 
 func NewFilter(sub []rune, action FilterAction, searchType SearchType) (*Filter, error) {
-	ff, err := GetSearchFunc(searchType, sub)
+	ff, timeRange, err := compileSearch(searchType, sub)
 	if err != nil {
 		return nil, err
 	}
@@ -115,12 +224,36 @@ func NewFilter(sub []rune, action FilterAction, searchType SearchType) (*Filter,
 		st:         searchType,
 		Action:     action,
 		TakeAction: af,
+		timeRange:  timeRange,
 	}, nil
 }
 
 func GetSearchFunc(searchType SearchType, sub []rune) (SearchFunc, error) {
+	ff, _, err := compileSearch(searchType, sub)
+	return ff, err
+}
+
+// compileSearch returns search func of the search type, along with parsed range for Time search type
+func compileSearch(searchType SearchType, sub []rune) (SearchFunc, *TimeRange, error) {
 	var ff SearchFunc
+	var timeRange *TimeRange
+	var err error
+	if searchType == SmartCase {
+		searchType = CaseInsensitive
+		if runes.HasUpper(sub) {
+			searchType = CaseSensitive
+		}
+	}
 	switch searchType {
+	case CaseInsensitive:
+		subLen := len(sub)
+		ff = func(str []rune) []int {
+			i := runes.IndexFold(str, sub)
+			if i == -1 {
+				return nil
+			}
+			return []int{i, i + subLen}
+		}
 	case CaseSensitive:
 		subLen := len(sub)
 		ff = func(str []rune) []int {
@@ -133,15 +266,24 @@ func GetSearchFunc(searchType SearchType, sub []rune) (SearchFunc, error) {
 	case RegEx:
 		re, err := regexp.Compile(string(sub))
 		if err != nil {
-			return nil, ErrBadFilterDefinition
+			return nil, nil, ErrBadFilterDefinition
 		}
 		ff = func(str []rune) []int {
 			return re.FindStringIndex(string(str))
 		}
+	case Expression:
+		ff, err = compileExpression(sub)
+	case Field:
+		ff, err = compileField(sub)
+	case Time:
+		var r TimeRange
+		if r, err = ParseTimeRange(string(sub)); err == nil {
+			ff, timeRange = r.searchFunc(), &r
+		}
 	default:
-		return nil, ErrBadFilterDefinition
+		err = ErrBadFilterDefinition
 	}
-	return ff, nil
+	return ff, timeRange, err
 }
 
 func IndexAll(searchFunc SearchFunc, runestack []rune) (indices [][]int) {
@@ -232,10 +374,10 @@ func getFilterAction(filterStr []rune) (FilterAction, error) {
 	sign := filterStr[0]
 	action, ok := FilterActionMap[sign]
 	if !ok {
-		return action, &UnknownFilterTypeError{string(sign), ""}
+		return action, &UnknownFilterTypeError{FilterTypeStr: string(sign)}
 	}
 	if len(filterStr) < FilterMinLength {
-		return action, &FilterTooShortError{string(filterStr), ""}
+		return action, &FilterTooShortError{FilterStr: string(filterStr)}
 	}
 	return action, nil
 }
@@ -249,22 +391,147 @@ func parseFilterLine(line string) (*Filter, error) {
 	if err != nil {
 		return nil, err
 	}
+	sub, mods, err := parseModifiers(trimmedLine[1:])
+	if err != nil {
+		return nil, err
+	}
 	filter, err := NewFilter(
-		trimmedLine[1:],
+		sub,
 		action,
-		CaseSensitive,
+		mods.searchType,
 	)
 	if err != nil {
 		return nil, err
 	}
+	filter.Color = mods.color
 	return filter, nil
 }
 
+// filterModifiers are given in braces right after filter sign
+type filterModifiers struct {
+	searchType SearchType
+	color      termbox.Attribute
+}
+
+// parseModifiers extracts comma separated modifiers given in braces before the pattern,
+// i.e. {RegEx}^ERROR or {i,color=red}warning. Several brace groups are allowed too: {smart}{color=cyan}
+// Braces are a part of the pattern unless every item in them is a search type or color, i.e. {"level":"error"}.
+// Empty braces end modifiers, so pattern looking like modifiers is given after them: {}{i}
+func parseModifiers(filterStr []rune) ([]rune, filterModifiers, error) {
+	mods := filterModifiers{searchType: CaseSensitive}
+	for len(filterStr) > 0 && filterStr[0] == '{' {
+		end := runes.Index(filterStr, []rune{'}'})
+		if end == -1 {
+			break
+		}
+		rest := []rune(strings.TrimLeftFunc(string(filterStr[end+1:]), unicode.IsSpace))
+		if end == 1 {
+			if len(rest) != 0 {
+				filterStr = rest
+			}
+			break
+		}
+		items := strings.Split(string(filterStr[1:end]), ",")
+		if !allModifiers(items) {
+			break
+		}
+		for _, mod := range items {
+			mod = strings.TrimSpace(mod)
+			if isColorModifier(mod) {
+				name := strings.TrimSpace(mod[strings.IndexRune(mod, '=')+1:])
+				color, ok := highlightColors[strings.ToLower(name)]
+				if !ok {
+					return nil, mods, &UnknownColorError{ColorStr: name}
+				}
+				mods.color = color
+				continue
+			}
+			mods.searchType, _ = searchTypeByName(mod)
+		}
+		filterStr = rest
+	}
+	return filterStr, mods, nil
+}
+
+// allModifiers returns true if every item is a search type or color=NAME
+func allModifiers(items []string) bool {
+	for _, mod := range items {
+		if _, ok := searchTypeByName(strings.TrimSpace(mod)); !ok && !isColorModifier(mod) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasModifiers returns true if line starts with filter sign followed by modifiers in braces
+func hasModifiers(line string) bool {
+	trimmed := []rune(strings.TrimLeftFunc(line, unicode.IsSpace))
+	if len(trimmed) == 0 {
+		return false
+	}
+	if _, ok := FilterActionMap[trimmed[0]]; !ok {
+		return false
+	}
+	sub, _, err := parseModifiers(trimmed[1:])
+	return err == nil && len(sub) != len(trimmed)-1
+}
+
+// isColorModifier returns true for color=NAME modifier, name is not checked
+func isColorModifier(mod string) bool {
+	eq := strings.IndexRune(mod, '=')
+	return eq != -1 && strings.EqualFold(strings.TrimSpace(mod[:eq]), "color")
+}
+
+func searchTypeByName(name string) (SearchType, bool) {
+	if st, ok := searchTypeAliases[strings.ToLower(name)]; ok {
+		return *st, true
+	}
+	for _, st := range SearchTypeMap {
+		if strings.EqualFold(st.Name, name) {
+			return st, true
+		}
+	}
+	return CaseSensitive, false
+}
+
+// withLocation sets file and line number to parsing error
+func withLocation(err error, filename string, line int) error {
+	switch er := err.(type) {
+	case *UnknownFilterTypeError:
+		er.Filename, er.Line = filename, line
+	case *FilterTooShortError:
+		er.Filename, er.Line = filename, line
+	case *UnknownColorError:
+		er.Filename, er.Line = filename, line
+	case *ExpressionError:
+		er.Filename, er.Line = filename, line
+	case *FieldFilterError:
+		er.Filename, er.Line = filename, line
+	case *TimeRangeError:
+		er.Filename, er.Line = filename, line
+	default:
+		return fmt.Errorf("%v%s", err, location(filename, line))
+	}
+	return err
+}
+
+// ParseFiltersFile reads filters, one per line. Lines starting with # are comments,
+// "include other.filters" adds filters of another file, relative paths are resolved from directory of current file
 func ParseFiltersFile(filename string) ([]*Filter, error) {
-	if err := utils.ValidateRegularFile(utils.ExpandHomePath(filename)); err != nil {
+	return parseFiltersFile(filename, make(map[string]bool))
+}
+
+func parseFiltersFile(filename string, included map[string]bool) ([]*Filter, error) {
+	path := utils.ExpandHomePath(filename)
+	if err := utils.ValidateRegularFile(path); err != nil {
 		return nil, err
 	}
-	f, err := os.Open(filename)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	included[path] = true
+	defer delete(included, path)
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -272,24 +539,44 @@ func ParseFiltersFile(filename string) ([]*Filter, error) {
 	scanner := bufio.NewScanner(f)
 
 	var filters []*Filter
-	for scanner.Scan() {
-		filter, err := parseFilterLine(scanner.Text())
-		if err != nil {
-			if er, ok := err.(*UnknownFilterTypeError); ok {
-				er.Filename = filename
-			} else if er, ok := err.(*FilterTooShortError); ok {
-				er.Filename = filename
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimLeftFunc(scanner.Text(), unicode.IsSpace)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if include := strings.TrimPrefix(line, includeDirective); include != line && strings.TrimSpace(include) != "" &&
+			unicode.IsSpace([]rune(include)[0]) {
+			include = utils.ExpandHomePath(strings.TrimSpace(include))
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(path), include)
 			}
-			return nil, err
+			if included[filepath.Clean(include)] {
+				return nil, &IncludeCycleError{include, filename, lineNo}
+			}
+			if err := utils.ValidateRegularFile(include); err != nil {
+				return nil, withLocation(err, filename, lineNo)
+			}
+			includedFilters, err := parseFiltersFile(include, included)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, includedFilters...)
+			continue
+		}
+		filter, err := parseFilterLine(line)
+		if err != nil {
+			return nil, withLocation(err, filename, lineNo)
 		}
 		if filter == nil {
 			continue
 		}
 		filters = append(filters, filter)
 	}
-	return filters, nil
+	return filters, scanner.Err()
 }
 
+// ParseFiltersOpt parses semicolon separated inline filters and filters files.
+// Files not found by path are looked up in PresetsDir by name
 func ParseFiltersOpt(optStr string) ([]*Filter, error) {
 	re := regexp.MustCompile("([^;]+);?")
 	var filters []*Filter
@@ -303,12 +590,15 @@ func ParseFiltersOpt(optStr string) ([]*Filter, error) {
 			continue
 		} else if err != nil {
 			switch err.(type) {
-			case *FilterTooShortError:
+			case *FilterTooShortError, *UnknownColorError, *ExpressionError, *FieldFilterError, *TimeRangeError:
 				return nil, err
 			default:
+				if err == ErrBadFilterDefinition && hasModifiers(m[1]) {
+					return nil, err // i.e. bad regex, filter with modifiers is not a file name
+				}
 			}
 		}
-		fileFilters, err := ParseFiltersFile(m[1])
+		fileFilters, err := ParseFiltersFile(resolvePreset(m[1]))
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,109 @@
+package filters
+
+import (
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func TestFilterStringRoundTrip(t *testing.T) {
+	tests := []struct {
+		sub    string
+		action FilterAction
+		st     SearchType
+		color  termbox.Attribute
+	}{
+		{"error", FilterIntersect, CaseSensitive, termbox.ColorDefault},
+		{"^ERROR", FilterUnion, RegEx, termbox.ColorDefault},
+		{"timeout", FilterHighlight, CaseInsensitive, termbox.ColorRed},
+		{"took [0-9]{4,}ms", FilterHighlight, RegEx, termbox.ColorCyan},
+		{"debug", FilterExclude, SmartCase, termbox.ColorDefault},
+		{"{\"level\":\"error\"", FilterIntersect, CaseSensitive, termbox.ColorDefault},
+		{"{i}", FilterIntersect, CaseSensitive, termbox.ColorDefault},
+		{"{regex,color=red}x", FilterHighlight, RegEx, termbox.ColorRed},
+		{"{color=nope}x", FilterIntersect, CaseSensitive, termbox.ColorDefault},
+		{"{}x", FilterIntersect, CaseSensitive, termbox.ColorDefault},
+		{"{}", FilterIntersect, CaseInsensitive, termbox.ColorDefault},
+	}
+	for _, test := range tests {
+		filter, err := NewFilter([]rune(test.sub), test.action, test.st)
+		if err != nil {
+			t.Fatalf("%q: %v", test.sub, err)
+		}
+		filter.Color = test.color
+		str := filter.String()
+		parsed, err := parseFilterLine(str)
+		if err != nil {
+			t.Errorf("%q: parsing %q: %v", test.sub, str, err)
+			continue
+		}
+		if string(parsed.sub) != test.sub || parsed.Action != test.action || parsed.st != test.st || parsed.Color != test.color {
+			t.Errorf("%q: %q parsed as %q %v %v %v", test.sub, str, string(parsed.sub), parsed.Action, parsed.st.Name, parsed.Color)
+		}
+	}
+}
+
+func TestParseModifiers(t *testing.T) {
+	tests := []struct {
+		str   string
+		sub   string
+		st    SearchType
+		color termbox.Attribute
+		err   bool
+	}{
+		{"plain", "plain", CaseSensitive, termbox.ColorDefault, false},
+		{"{RegEx}^ERROR", "^ERROR", RegEx, termbox.ColorDefault, false},
+		{"{i, color=red} warning", "warning", CaseInsensitive, termbox.ColorRed, false},
+		{"{smart}{color=cyan}x", "x", SmartCase, termbox.ColorCyan, false},
+		{"{\"level\":\"error\"}", "{\"level\":\"error\"}", CaseSensitive, termbox.ColorDefault, false},
+		{"{i,level}", "{i,level}", CaseSensitive, termbox.ColorDefault, false},
+		{"{CaseS}{\"level\"", "{\"level\"", CaseSensitive, termbox.ColorDefault, false},
+		{"{unclosed", "{unclosed", CaseSensitive, termbox.ColorDefault, false},
+		{"{}{i}", "{i}", CaseSensitive, termbox.ColorDefault, false},
+		{"{i}{}", "{}", CaseInsensitive, termbox.ColorDefault, false},
+		{"{f}level=error", "level=error", Field, termbox.ColorDefault, false},
+		{"{color=nope}x", "", CaseSensitive, termbox.ColorDefault, true},
+	}
+	for _, test := range tests {
+		sub, mods, err := parseModifiers([]rune(test.str))
+		if test.err {
+			if err == nil {
+				t.Errorf("%q: expected error", test.str)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.str, err)
+			continue
+		}
+		if string(sub) != test.sub || mods.searchType != test.st || mods.color != test.color {
+			t.Errorf("%q: got %q %v %v, expected %q %v %v", test.str, string(sub), mods.searchType.Name, mods.color,
+				test.sub, test.st.Name, test.color)
+		}
+	}
+}
+
+func TestParseFiltersOpt(t *testing.T) {
+	filters, err := ParseFiltersOpt("&error;-{i}debug;~{regex,color=red}took [0-9]+ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filters) != 3 {
+		t.Fatalf("expected 3 filters, got %d", len(filters))
+	}
+	tests := []struct {
+		opt string
+		err error
+	}{
+		{"&{regex}[unclosed", ErrBadFilterDefinition},
+		{"-{RegEx,color=red}(", ErrBadFilterDefinition},
+	}
+	for _, test := range tests {
+		if _, err := ParseFiltersOpt(test.opt); err != test.err {
+			t.Errorf("%q: got %v, expected %v", test.opt, err, test.err)
+		}
+	}
+	if _, err := ParseFiltersOpt("no-such-filters-file"); err == nil {
+		t.Errorf("missing filters file should be reported")
+	}
+}
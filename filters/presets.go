@@ -0,0 +1,90 @@
+package filters
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tigrawap/slit/utils"
+)
+
+// PresetsDir holds named filter presets, one filters file per preset
+var PresetsDir string
+
+var ErrBadPresetName = errors.New("Bad preset name")
+
+func presetPath(name string) (string, error) {
+	if PresetsDir == "" || name == "" || name == "." || name == ".." || strings.ContainsRune(name, filepath.Separator) {
+		return "", ErrBadPresetName
+	}
+	return filepath.Join(PresetsDir, name), nil
+}
+
+// resolvePreset returns path of the preset with given name, if filename does not exist by itself
+func resolvePreset(filename string) string {
+	if _, err := os.Stat(utils.ExpandHomePath(filename)); err == nil {
+		return filename
+	}
+	if path, err := presetPath(filename); err == nil {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filename
+}
+
+// SavePreset writes filters chain in filters file syntax, disabled filters are written commented out
+func SavePreset(name string, chain []*Filter) error {
+	path, err := presetPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(PresetsDir, os.ModePerm); err != nil {
+		return err
+	}
+	var content strings.Builder
+	for _, filter := range chain {
+		if filter.Disabled {
+			content.WriteString("# ")
+		}
+		content.WriteString(filter.String())
+		content.WriteByte('\n')
+	}
+	tmpPath := path + "_tmp"
+	if err := ioutil.WriteFile(tmpPath, []byte(content.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func LoadPreset(name string) ([]*Filter, error) {
+	path, err := presetPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFiltersFile(path)
+}
+
+// ListPresets returns sorted names of saved presets, no presets if directory does not exist yet
+func ListPresets() ([]string, error) {
+	if PresetsDir == "" {
+		return nil, nil
+	}
+	files, err := ioutil.ReadDir(PresetsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		if file.Mode().IsRegular() && !strings.HasSuffix(file.Name(), "_tmp") {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
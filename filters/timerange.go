@@ -0,0 +1,183 @@
+package filters
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tigrawap/slit/timestamps"
+)
+
+// TimeRange keeps lines with timestamp at or after since and before until, either bound may be missing.
+// Range given without date, i.e. 14:00..14:10, matches that time of any day.
+// Single time of day bound, i.e. 14:00.., is taken on the day of the first timestamp of the log, see Anchor
+type TimeRange struct {
+	since, until       time.Time     // zero if not limited
+	timeOfDay          bool          // bounds are sinceTOD and untilTOD, given as time passed since midnight
+	sinceTOD, untilTOD time.Duration // -1 if not limited
+	anchor             *dayAnchor    // set if the only bound is time of day
+}
+
+// dayAnchor is the day single time of day bound is taken on, shared by copies of the range
+type dayAnchor struct {
+	sync.Mutex
+	day time.Time
+	set bool
+}
+
+// timeRangeSeparator separates bounds of the range, i.e. 14:00..14:10, 2019-03-01 14:00.. or ..30m
+const timeRangeSeparator = ".."
+
+// ParseTimeRange parses SINCE..UNTIL, bound without separator is SINCE. Bound is either date and time
+// (2019-03-01 14:32), time of day (14:32:05) or duration meaning that long ago (30m)
+func ParseTimeRange(str string) (TimeRange, error) {
+	sinceStr, untilStr := str, ""
+	if i := strings.Index(str, timeRangeSeparator); i != -1 {
+		sinceStr, untilStr = str[:i], str[i+len(timeRangeSeparator):]
+	}
+	r := TimeRange{sinceTOD: -1, untilTOD: -1}
+	var sinceIsTOD, untilIsTOD, ok bool
+	if r.since, r.sinceTOD, sinceIsTOD, ok = parseTimeBound(sinceStr); !ok {
+		return r, &TimeRangeError{Range: str}
+	}
+	if r.until, r.untilTOD, untilIsTOD, ok = parseTimeBound(untilStr); !ok {
+		return r, &TimeRangeError{Range: str}
+	}
+	switch {
+	case r.since.IsZero() && r.until.IsZero() && !sinceIsTOD && !untilIsTOD:
+		return r, &TimeRangeError{Range: str}
+	case sinceIsTOD && !r.until.IsZero():
+		// time of day is taken on the same day as the other bound
+		r.since = onDay(r.until, r.sinceTOD)
+		if r.since.After(r.until) {
+			r.since = r.since.AddDate(0, 0, -1)
+		}
+	case untilIsTOD && !r.since.IsZero():
+		r.until = onDay(r.since, r.untilTOD)
+		if !r.until.After(r.since) {
+			r.until = r.until.AddDate(0, 0, 1)
+		}
+	case sinceIsTOD && untilIsTOD:
+		r.timeOfDay = true
+	case sinceIsTOD || untilIsTOD:
+		r.anchor = &dayAnchor{}
+	}
+	return r, nil
+}
+
+// parseTimeBound returns absolute time or time of day, with isTOD set. Empty bound is not limited
+func parseTimeBound(str string) (t time.Time, tod time.Duration, isTOD bool, ok bool) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return time.Time{}, -1, false, true
+	}
+	if d, err := time.ParseDuration(str); err == nil {
+		if d > 0 {
+			d = -d
+		}
+		return time.Now().Add(d), -1, false, true
+	}
+	if tod, ok := timestamps.ParseTimeOfDay(str); ok {
+		return time.Time{}, tod, true, true
+	}
+	t, err := timestamps.ParseQuery(str, time.Now())
+	return t, -1, false, err == nil
+}
+
+// onDay returns time of day tod on the day t belongs to
+func onDay(t time.Time, tod time.Duration) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location()).Add(tod)
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return t.Sub(onDay(t, 0))
+}
+
+// Anchored returns false while single time of day bound is not taken on some day yet
+func (r TimeRange) Anchored() bool {
+	if r.anchor == nil {
+		return true
+	}
+	r.anchor.Lock()
+	defer r.anchor.Unlock()
+	return r.anchor.set
+}
+
+// Anchor takes single time of day bound on the day of t, unless it is taken on some day already.
+// Range which is not anchored beforehand is anchored by the first timestamp it checks
+func (r TimeRange) Anchor(t time.Time) {
+	r.anchored(t)
+}
+
+// anchored returns range with single time of day bound taken on the day it is anchored to, anchoring it to t first
+func (r TimeRange) anchored(t time.Time) TimeRange {
+	if r.anchor == nil {
+		return r
+	}
+	r.anchor.Lock()
+	if !r.anchor.set {
+		r.anchor.day, r.anchor.set = t, true
+	}
+	day := r.anchor.day
+	r.anchor.Unlock()
+	if r.sinceTOD >= 0 {
+		r.since = onDay(day, r.sinceTOD)
+	}
+	if r.untilTOD >= 0 {
+		r.until = onDay(day, r.untilTOD)
+	}
+	return r
+}
+
+// Contains returns true if t is within the range
+func (r TimeRange) Contains(t time.Time) bool {
+	r = r.anchored(t)
+	if !r.timeOfDay {
+		return (r.since.IsZero() || !t.Before(r.since)) && (r.until.IsZero() || t.Before(r.until))
+	}
+	d := sinceMidnight(t)
+	afterSince := r.sinceTOD < 0 || d >= r.sinceTOD
+	beforeUntil := r.untilTOD < 0 || d < r.untilTOD
+	if r.sinceTOD >= 0 && r.untilTOD >= 0 && r.untilTOD < r.sinceTOD { // across midnight, i.e. 23:50..00:10
+		return afterSince || beforeUntil
+	}
+	return afterSince && beforeUntil
+}
+
+// TimeOfDay returns true if both bounds are given without date and range matches any day
+func (r TimeRange) TimeOfDay() bool { return r.timeOfDay }
+
+// Bounds returns since and until of the range, zero if not limited. Range given as time of day is taken on
+// the day of given time
+func (r TimeRange) Bounds(day time.Time) (since, until time.Time) {
+	r = r.anchored(day)
+	if !r.timeOfDay {
+		return r.since, r.until
+	}
+	if r.sinceTOD >= 0 {
+		since = onDay(day, r.sinceTOD)
+	}
+	if r.untilTOD >= 0 {
+		until = onDay(day, r.untilTOD)
+		if r.sinceTOD >= 0 && r.untilTOD < r.sinceTOD {
+			until = until.AddDate(0, 0, 1)
+		}
+	}
+	return since, until
+}
+
+// searchFunc matches lines with timestamp within the range, match covers the whole line
+func (r TimeRange) searchFunc() SearchFunc {
+	return func(str []rune) []int {
+		window := str
+		if len(window) > timestamps.SearchWindow {
+			window = window[:timestamps.SearchWindow]
+		}
+		t, ok := timestamps.Parse(string(window))
+		if !ok || !r.Contains(t) {
+			return nil
+		}
+		return []int{0, len(str)}
+	}
+}
@@ -0,0 +1,178 @@
+package filters
+
+import (
+	"testing"
+	"time"
+)
+
+func localTime(str string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", str, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestTimeRangeContains(t *testing.T) {
+	tests := []struct {
+		rangeStr string
+		times    map[string]bool
+	}{
+		{"2019-03-01 14:00..2019-03-01 14:10", map[string]bool{
+			"2019-03-01 13:59:59": false,
+			"2019-03-01 14:00:00": true,
+			"2019-03-01 14:09:59": true,
+			"2019-03-01 14:10:00": false,
+		}},
+		{"2019-03-01 14:00..", map[string]bool{
+			"2019-03-01 13:59:59": false,
+			"2020-01-01 00:00:00": true,
+		}},
+		{"2019-03-01 14:00", map[string]bool{
+			"2019-03-01 13:59:59": false,
+			"2019-03-01 14:00:00": true,
+		}},
+		{"..2019-03-01 14:00", map[string]bool{
+			"2019-03-01 13:59:59": true,
+			"2019-03-01 14:00:00": false,
+		}},
+		{"14:00..14:10", map[string]bool{
+			"2019-03-01 14:05:00": true,
+			"2019-05-07 14:05:00": true,
+			"2019-03-01 14:10:00": false,
+			"2019-03-01 13:00:00": false,
+		}},
+		{"23:50..00:10", map[string]bool{
+			"2019-03-01 23:55:00": true,
+			"2019-03-02 00:05:00": true,
+			"2019-03-02 00:10:00": false,
+			"2019-03-01 12:00:00": false,
+		}},
+		{"2019-03-01 23:50..00:10", map[string]bool{
+			"2019-03-01 23:55:00": true,
+			"2019-03-02 00:05:00": true,
+			"2019-03-03 00:05:00": false,
+		}},
+		{"23:50..2019-03-02 00:10", map[string]bool{
+			"2019-03-01 23:55:00": true,
+			"2019-03-02 23:55:00": false,
+		}},
+	}
+	for _, test := range tests {
+		r, err := ParseTimeRange(test.rangeStr)
+		if err != nil {
+			t.Fatalf("%q: %v", test.rangeStr, err)
+		}
+		for str, expected := range test.times {
+			if r.Contains(localTime(str)) != expected {
+				t.Errorf("%q contains %s: expected %v", test.rangeStr, str, expected)
+			}
+		}
+	}
+}
+
+func TestTimeRangeOpenTimeOfDay(t *testing.T) {
+	type check struct {
+		time     string
+		expected bool
+	}
+	tests := []struct {
+		rangeStr string
+		checks   []check // in order, since the first one anchors the range to its day
+	}{
+		{"14:00..", []check{
+			{"2019-03-01 15:00:00", true},
+			{"2019-03-02 09:00:00", true},
+			{"2019-03-01 13:59:59", false},
+			{"2019-02-28 15:00:00", false},
+		}},
+		{"14:00", []check{
+			{"2019-03-01 10:00:00", false},
+			{"2019-03-01 14:00:00", true},
+			{"2019-03-02 10:00:00", true},
+		}},
+		{"..14:00", []check{
+			{"2019-03-01 10:00:00", true},
+			{"2019-02-28 16:00:00", true},
+			{"2019-03-01 14:00:00", false},
+			{"2019-03-02 10:00:00", false},
+		}},
+	}
+	for _, test := range tests {
+		r, err := ParseTimeRange(test.rangeStr)
+		if err != nil {
+			t.Fatalf("%q: %v", test.rangeStr, err)
+		}
+		if r.TimeOfDay() {
+			t.Errorf("%q should not match every day", test.rangeStr)
+		}
+		for _, check := range test.checks {
+			if r.Contains(localTime(check.time)) != check.expected {
+				t.Errorf("%q contains %s: expected %v", test.rangeStr, check.time, check.expected)
+			}
+		}
+	}
+}
+
+func TestTimeRangeOpenTimeOfDayBounds(t *testing.T) {
+	r, err := ParseTimeRange("14:00..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Anchored() {
+		t.Errorf("range should not be anchored before the first timestamp")
+	}
+	since, until := r.Bounds(localTime("2019-03-01 10:00:00"))
+	if !since.Equal(localTime("2019-03-01 14:00:00")) || !until.IsZero() {
+		t.Errorf("bounds are taken on the day of the first timestamp, got %v..%v", since, until)
+	}
+	if !r.Contains(localTime("2019-03-02 09:00:00")) {
+		t.Errorf("range anchored by bounds should contain the next morning")
+	}
+}
+
+func TestTimeRangeAgo(t *testing.T) {
+	r, err := ParseTimeRange("30m..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if !r.Contains(now) || r.Contains(now.Add(-time.Hour)) {
+		t.Errorf("30m.. should contain only last 30 minutes")
+	}
+}
+
+func TestTimeRangeErrors(t *testing.T) {
+	for _, str := range []string{"", "..", "yesterday", "14:00..later", "2019-13-01 14:00.."} {
+		if _, err := ParseTimeRange(str); err == nil {
+			t.Errorf("%q: expected error", str)
+		}
+	}
+}
+
+func TestTimeFilter(t *testing.T) {
+	filter, err := NewFilter([]rune("14:00..14:10"), FilterIntersect, Time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := filter.TimeRange(); !ok {
+		t.Errorf("Time filter should have time range")
+	}
+	other, _ := NewFilter([]rune("14:00"), FilterIntersect, CaseSensitive)
+	if _, ok := other.TimeRange(); ok {
+		t.Errorf("CaseS filter should not have time range")
+	}
+	search, err := GetSearchFunc(Time, []rune("14:00..14:10"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := []rune("2019-03-01 14:05:00 INFO started")
+	if m := search(line); !equalRange(m, []int{0, len(line)}) {
+		t.Errorf("line in range should match whole, got %v", m)
+	}
+	for _, line := range []string{"2019-03-01 14:15:00 INFO started", "INFO no timestamp"} {
+		if m := search([]rune(line)); m != nil {
+			t.Errorf("%q should not match, got %v", line, m)
+		}
+	}
+}
@@ -4,7 +4,7 @@ go 1.12
 
 require (
 	code.cloudfoundry.org/bytefmt v0.0.0-20180906201452-2aa6f33b730c
-	github.com/mattn/go-runewidth v0.0.3 // indirect
+	github.com/mattn/go-runewidth v0.0.3
 	github.com/nsf/termbox-go v0.0.0-20180819125858-b66b20ab708e
 	github.com/ogier/pflag v0.0.1
 	github.com/onsi/ginkgo v1.8.0 // indirect
@@ -0,0 +1,177 @@
+package slit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nsf/termbox-go"
+	"github.com/tigrawap/slit/timestamps"
+)
+
+var errInvalidGoto = errors.New("Expected line number or percentage, i.e. 1234 or 75%")
+
+// goTo moves view to line number or to percentage of the file, given as typed in go-to prompt
+func (v *viewer) goTo(input string) {
+	input = strings.TrimSpace(input)
+	var pos Pos
+	var err error
+	if strings.HasSuffix(input, "%") {
+		var percent float64
+		percent, err = strconv.ParseFloat(strings.TrimSuffix(input, "%"), 64)
+		if err == nil && (percent < 0 || percent > 100) {
+			err = errInvalidGoto
+		}
+		if err == nil {
+			pos, err = v.percentPos(percent)
+		}
+	} else {
+		var line int64
+		line, err = strconv.ParseInt(input, 10, 64)
+		if err == nil && line < 1 {
+			err = errInvalidGoto
+		}
+		if err == nil {
+			pos, err = v.linePos(LineNo(line - 1))
+		}
+	}
+	if err == io.EOF {
+		v.navigateEnd()
+		return
+	}
+	if err != nil {
+		v.info.setMessage(ibMessage{str: errInvalidGoto.Error(), color: termbox.ColorRed})
+		return
+	}
+	v.navigateToVisible(pos)
+}
+
+// goToTime moves view to the first line with timestamp at or after given time.
+// Time is either absolute, i.e. "14:32:05" or "2019-03-01 14:32", or relative to top line, i.e. "+5m" or "-30s"
+func (v *viewer) goToTime(input string) {
+	input = strings.TrimSpace(input)
+	var parser timestamps.Parser
+	top, _, haveTop := v.fetcher.timestampAfter(v.buffer.currentLine().Offset, &parser, timeSearchMaxLines)
+	var target time.Time
+	if strings.HasPrefix(input, "+") || strings.HasPrefix(input, "-") {
+		d, err := time.ParseDuration(input)
+		if err != nil {
+			v.info.setMessage(ibMessage{str: "Expected duration, i.e. +5m or -30s", color: termbox.ColorRed})
+			return
+		}
+		if !haveTop {
+			v.info.setMessage(ibMessage{str: "No timestamp found near top line", color: termbox.ColorRed})
+			return
+		}
+		target = top.Add(d)
+	} else {
+		if !haveTop {
+			top = time.Now()
+		}
+		t, err := timestamps.ParseQuery(input, top)
+		if err != nil {
+			v.info.setMessage(ibMessage{str: "Expected time, i.e. 14:32:05 or 2019-03-01 14:32", color: termbox.ColorRed})
+			return
+		}
+		target = t
+	}
+	offset, err := v.fetcher.timeOffset(v.ctx, target)
+	if err == io.EOF {
+		v.navigateEnd()
+		v.info.setMessage(ibMessage{str: "No lines at or after " + target.Format("2006-01-02 15:04:05"), color: termbox.ColorYellow})
+		return
+	}
+	if err != nil {
+		return
+	}
+	v.navigateToVisible(Pos{v.fetcher.resolveLine(offset), offset})
+}
+
+// linePos returns position of the line, exact one if line is already indexed, estimated by average line length otherwise
+func (v *viewer) linePos(line LineNo) (Pos, error) {
+	offset, exact := v.fetcher.index.estimateOffset(line)
+	if exact {
+		if offset >= v.fetcher.size() {
+			return Pos{}, io.EOF
+		}
+		return Pos{line, offset}, nil
+	}
+	return v.offsetPos(offset)
+}
+
+// percentPos returns position of the line at given percentage of file size
+func (v *viewer) percentPos(percent float64) (Pos, error) {
+	return v.offsetPos(Offset(float64(v.fetcher.size()) * percent / 100))
+}
+
+// offsetPos returns position of the first line starting at or after offset
+func (v *viewer) offsetPos(offset Offset) (Pos, error) {
+	if offset >= v.fetcher.size() {
+		return Pos{}, io.EOF
+	}
+	lineOffset, err := v.fetcher.findLine(offset)
+	if err != nil {
+		return Pos{}, err
+	}
+	return Pos{v.fetcher.resolveLine(lineOffset), lineOffset}, nil
+}
+
+// navigateToVisible moves view to pos, or to the nearest line not excluded by filters.
+// Lines are looked up in background, since there might be no such lines for a long way
+func (v *viewer) navigateToVisible(pos Pos) {
+	v.runTask(func(ctx context.Context, progress searchProgress) func() {
+		nearest, ok := v.nearestVisible(ctx, pos, progress)
+		return func() {
+			if ok {
+				v.info.reset(ibModeStatus)
+				v.navigateToPos(nearest)
+			} else {
+				v.info.setMessage(ibMessage{str: "No lines match filters", color: termbox.ColorRed})
+			}
+		}
+	})
+}
+
+// nearestVisible looks for visible lines both after and before pos, returns closest of them
+func (v *viewer) nearestVisible(ctx context.Context, pos Pos, progress searchProgress) (Pos, bool) {
+	visible := func(Line) bool { return true }
+	next := v.fetcher.searchParallel(ctx, pos, true, visible, progress)
+	if next != POS_NOT_FOUND && next.Offset == pos.Offset {
+		return next, true
+	}
+	prev := POS_NOT_FOUND
+	if pos.Offset > 0 {
+		from := Pos{POS_UNKNOWN, pos.Offset - 1}
+		if pos.Line > 0 {
+			from.Line = pos.Line - 1
+		}
+		prev = v.fetcher.searchParallel(ctx, from, false, visible, progress)
+	}
+	switch {
+	case next != POS_NOT_FOUND && prev != POS_NOT_FOUND:
+		if distance(pos, prev) < distance(pos, next) {
+			return prev, true
+		}
+		return next, true
+	case next != POS_NOT_FOUND:
+		return next, true
+	case prev != POS_NOT_FOUND:
+		return prev, true
+	}
+	return Pos{}, false
+}
+
+// distance between positions in lines if both are known, in bytes otherwise
+func distance(a, b Pos) int64 {
+	d := int64(a.Offset - b.Offset)
+	if a.Line >= 0 && b.Line >= 0 {
+		d = int64(a.Line - b.Line)
+	}
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -2,6 +2,7 @@ package slit
 
 import (
 	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/nsf/termbox-go"
@@ -30,6 +31,11 @@ const (
 	ibModeMessage
 	ibModeKeepCharacters
 	ibModeHighlight
+	ibModeGoto
+	ibModeGotoTime
+	ibModeSavePreset
+	ibModeContext
+	ibModeTimeRange
 )
 
 type infobar struct {
@@ -42,10 +48,15 @@ type infobar struct {
 	totalLines     LineNo
 	currentLine    *Pos
 	filtersEnabled *bool
+	lineContext    *contextLines
+	json           *bool
 	keepChars      *int
 	history        ibHistory
 	searchType     filters.SearchType
 	message        ibMessage
+	label          string   // name of the current tab, empty if only one input opened
+	command        *command // command being paged, nil if not running one
+	matches        *matchCounter
 }
 
 type ibMessage struct {
@@ -55,12 +66,61 @@ type ibMessage struct {
 
 const ibHistorySize = 1000
 
+// First line of history file, files without it are plain text of older versions, with one search string per line
+const ibHistoryHeader = "#slit-history v2"
+
 type ibHistory struct {
-	buffer       [][]rune
-	wlock        sync.RWMutex
-	pos          int    // position from the end of file. New records appended, so 0 is always "before" last record with ==1 being last record
-	currentInput []rune // when navigating from zero position will hold input use entered and displayed once back to zero Line
-	loaded       bool
+	buffer            []ibHistoryEntry
+	wlock             sync.RWMutex
+	pos               int    // position in order. 0 is always "before" first record of order with ==1 being first record
+	order             []int  // indices of buffer in navigation order, entries of current mode go first, newest first
+	currentInput      []rune // when navigating from zero position will hold input use entered and displayed once back to zero Line
+	currentSearchType filters.SearchType
+	loaded            bool
+	legacy            bool // file is in plain text format, will be rewritten on next save
+}
+
+type ibHistoryEntry struct {
+	Str        string      `json:"str"`
+	SearchType uint8       `json:"type"`
+	Mode       infobarMode `json:"mode"` // ibModeStatus for entries of plain text history, where mode is unknown
+}
+
+// ibModeNames are saved in history instead of mode numbers, which change once new mode is inserted
+var ibModeNames = map[infobarMode]string{
+	ibModeStatus:         "status",
+	ibModeSearch:         "search",
+	ibModeBackSearch:     "back-search",
+	ibModeFilter:         "filter",
+	ibModeAppend:         "append",
+	ibModeExclude:        "exclude",
+	ibModeSave:           "save",
+	ibModeMessage:        "message",
+	ibModeKeepCharacters: "keep-chars",
+	ibModeHighlight:      "highlight",
+	ibModeGoto:           "goto",
+	ibModeGotoTime:       "goto-time",
+	ibModeSavePreset:     "save-preset",
+	ibModeContext:        "context",
+	ibModeTimeRange:      "time-range",
+}
+
+func (m infobarMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ibModeNames[m])
+}
+
+func (m *infobarMode) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	for mode, modeName := range ibModeNames {
+		if modeName == name {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown mode \"%s\"", name)
 }
 
 func (v *infobar) moveCursor(direction int) error {
@@ -79,6 +139,7 @@ func (v *infobar) reset(mode infobarMode) {
 	v.cx = 0
 	v.editBuffer = v.editBuffer[:0]
 	v.mode = mode
+	v.history.pos = 0
 	v.draw()
 }
 
@@ -97,15 +158,37 @@ func (v *infobar) statusBar() {
 	for i := 0; i < len(str); i++ {
 		termbox.SetCell(v.width-len(str)+i, v.y, str[i], termbox.ColorYellow, termbox.ColorDefault)
 	}
+	x := 1
+	if v.label != "" {
+		x = v.statusText(x, "["+v.label+"]", termbox.ColorCyan)
+	}
+	if v.command != nil {
+		status, color := v.command.status()
+		x = v.statusText(x, status, color)
+	}
 	if !*v.filtersEnabled {
-		str := []rune("[-FILTERS]")
-		for i := 0; i < len(str) && i+1 < v.width; i++ {
-			termbox.SetCell(i+1, v.y, str[i], termbox.ColorMagenta, termbox.ColorDefault)
-		}
+		x = v.statusText(x, "[-FILTERS]", termbox.ColorMagenta)
+	} else if v.lineContext.enabled() {
+		x = v.statusText(x, "["+v.lineContext.status()+"]", termbox.ColorYellow)
+	}
+	if *v.json {
+		x = v.statusText(x, "[JSON]", termbox.ColorCyan)
+	}
+	if matches := v.matches.status(); matches != "" {
+		x = v.statusText(x, "["+matches+"]", termbox.ColorGreen)
 	}
 	termbox.Flush()
 }
 
+// statusText draws str on the left side of status bar starting from x, returns position for next text
+func (v *infobar) statusText(x int, str string, color termbox.Attribute) int {
+	runeStr := []rune(str)
+	for i := 0; i < len(runeStr) && x+i < v.width; i++ {
+		termbox.SetCell(x+i, v.y, runeStr[i], color, termbox.ColorDefault)
+	}
+	return x + len(runeStr) + 1
+}
+
 func (v *infobar) showSearch() {
 	v.moveCursorToPosition(v.cx)
 	v.syncSearchString()
@@ -139,6 +222,21 @@ func (v *infobar) draw() {
 		v.editBuffer = []rune(strconv.Itoa(*v.keepChars))
 		v.showSearch()
 		v.moveCursorToPosition(len(v.editBuffer))
+	case ibModeGoto:
+		termbox.SetCell(0, v.y, ':', termbox.ColorYellow, termbox.ColorDefault)
+		v.showSearch()
+	case ibModeGotoTime:
+		termbox.SetCell(0, v.y, '@', termbox.ColorYellow, termbox.ColorDefault)
+		v.showSearch()
+	case ibModeSavePreset:
+		termbox.SetCell(0, v.y, 'P', termbox.ColorMagenta, termbox.ColorDefault)
+		v.showSearch()
+	case ibModeContext:
+		termbox.SetCell(0, v.y, 'c', termbox.ColorYellow, termbox.ColorDefault)
+		v.showSearch()
+	case ibModeTimeRange:
+		termbox.SetCell(0, v.y, 't', termbox.ColorGreen, termbox.ColorDefault)
+		v.showSearch()
 	case ibModeStatus:
 		v.statusBar()
 	case ibModeMessage:
@@ -324,45 +422,71 @@ func (history *ibHistory) load() {
 	}
 	defer f.Close()
 	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return
+	}
+	if scanner.Text() != ibHistoryHeader {
+		history.legacy = true
+		for ok := true; ok; ok = scanner.Scan() {
+			history.buffer = append(history.buffer, ibHistoryEntry{
+				Str:        scanner.Text(),
+				SearchType: filters.CaseSensitive.ID,
+				Mode:       ibModeStatus,
+			})
+		}
+		return
+	}
 	for scanner.Scan() {
-		history.buffer = append(history.buffer, []rune(scanner.Text()))
+		var entry ibHistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			logging.Debug(fmt.Sprintf("Skipping bad history entry: %s", err))
+			continue
+		}
+		history.buffer = append(history.buffer, entry)
 	}
 }
 
 func (v *infobar) addToHistory() {
 	switch v.mode {
-	case ibModeKeepCharacters, ibModeSave:
+	case ibModeKeepCharacters, ibModeSave, ibModeGoto, ibModeGotoTime, ibModeSavePreset, ibModeContext:
 		return
 	default:
-		v.history.add(v.editBuffer)
+		v.history.add(ibHistoryEntry{
+			Str:        string(v.editBuffer),
+			SearchType: v.searchType.ID,
+			Mode:       v.mode,
+		})
 	}
 }
 
-func (history *ibHistory) add(str []rune) {
-	if len(str) == 0 {
+func (history *ibHistory) add(entry ibHistoryEntry) {
+	if len(entry.Str) == 0 {
 		return // no need to save empty strings
 	}
 	history.load()
-	data := make([]rune, len(str))
-	copy(data, str)
 	history.wlock.Lock()
-	history.buffer = append(history.buffer, data)
+	history.buffer = append(history.buffer, entry)
 	history.pos = 0
 	history.wlock.Unlock()
-	go history.save(str)
+	go history.save(entry)
 }
 
-func (history *ibHistory) save(str []rune) {
+func (history *ibHistory) save(entry ibHistoryEntry) {
 	history.wlock.Lock()
 	defer history.wlock.Unlock()
 	os.MkdirAll(filepath.Dir(config.historyPath), os.ModePerm)
+	if history.legacy || len(history.buffer) == 1 {
+		history.rewrite(history.buffer) // converting plain text history or starting a new file
+		return
+	}
 	f, err := os.OpenFile(config.historyPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
 	if err != nil {
 		logging.Debug(fmt.Sprintf("Could not open history file: %s", err))
 		return
 	}
 	defer f.Close()
-	f.Write([]byte(string(str) + "\n"))
+	data, _ := json.Marshal(entry)
+	f.Write(append(data, '\n'))
 	logging.Debug("len, size", len(history.buffer), ibHistorySize)
 	if len(history.buffer) >= ibHistorySize {
 		history.trim()
@@ -370,12 +494,18 @@ func (history *ibHistory) save(str []rune) {
 }
 
 func (history *ibHistory) trim() {
+	history.rewrite(history.buffer[len(history.buffer)-ibHistorySize/100*80:])
+}
+
+// rewrite replaces history file with given entries
+func (history *ibHistory) rewrite(keptHistory []ibHistoryEntry) {
 	tmpPath := config.historyPath + "_tmp"
 	tmpFile := utils.OpenRewrite(tmpPath)
 	writer := bufio.NewWriter(tmpFile)
-	keptHistory := history.buffer[len(history.buffer)-ibHistorySize/100*80:]
-	for _, str := range keptHistory {
-		writer.WriteString(string(str) + "\n")
+	writer.WriteString(ibHistoryHeader + "\n")
+	for _, entry := range keptHistory {
+		data, _ := json.Marshal(entry)
+		writer.Write(append(data, '\n'))
 	}
 
 	if err := writer.Flush(); err != nil {
@@ -387,6 +517,7 @@ func (history *ibHistory) trim() {
 		return
 	}
 	history.buffer = keptHistory
+	history.legacy = false
 	os.Rename(tmpPath, config.historyPath)
 }
 
@@ -408,14 +539,35 @@ func (v *infobar) onKeyDown() {
 	}
 }
 
+// orderFor returns indices of entries, the ones added in given mode go first, newest first
+func (history *ibHistory) orderFor(mode infobarMode) []int {
+	history.wlock.RLock()
+	defer history.wlock.RUnlock()
+	order := make([]int, 0, len(history.buffer))
+	for i := len(history.buffer) - 1; i >= 0; i-- {
+		if history.buffer[i].Mode == mode {
+			order = append(order, i)
+		}
+	}
+	for i := len(history.buffer) - 1; i >= 0; i-- {
+		if history.buffer[i].Mode != mode {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
 func (v *infobar) navigateHistory(i int) {
 	v.history.load()
+	if v.history.pos == 0 {
+		v.history.order = v.history.orderFor(v.mode)
+	}
 	target := v.history.pos + i
-	if len(v.history.buffer) == 0 {
+	if len(v.history.order) == 0 {
 		target = 0
 	}
-	if target > len(v.history.buffer) {
-		target = len(v.history.buffer)
+	if target > len(v.history.order) {
+		target = len(v.history.order)
 	}
 	if target < 0 {
 		target = 0
@@ -428,17 +580,21 @@ func (v *infobar) navigateHistory(i int) {
 		if v.history.pos != 0 {
 			v.history.pos = target
 			v.editBuffer = v.history.currentInput
+			v.searchType = v.history.currentSearchType
 			onPosChange()
 		}
 		return // Does not matter where we are going, but nothing to do here.
 	}
 	if v.history.pos == 0 { // Moved out from zero-search to existing search string
 		v.history.currentInput = v.editBuffer
+		v.history.currentSearchType = v.searchType
 	}
 	v.history.pos = target
-	targetString := v.history.buffer[len(v.history.buffer)-target]
-	v.editBuffer = make([]rune, len(targetString))
-	copy(v.editBuffer, targetString)
+	entry := v.history.buffer[v.history.order[target-1]]
+	v.editBuffer = []rune(entry.Str)
+	if st, ok := filters.SearchTypeMap[entry.SearchType]; ok {
+		v.searchType = st
+	}
 	onPosChange()
 }
 
@@ -451,8 +607,12 @@ func (v *infobar) syncSearchString() {
 	// TODO: All setCelling here need to be moved to some nicer wrapper funcs
 	var color termbox.Attribute
 	switch v.mode {
-	case ibModeKeepCharacters:
+	case ibModeKeepCharacters, ibModeGoto, ibModeGotoTime, ibModeContext:
 		color = termbox.ColorYellow
+	case ibModeTimeRange:
+		color = filters.Time.Color
+	case ibModeSavePreset:
+		color = termbox.ColorMagenta
 	default:
 		color = v.searchType.Color
 	}
@@ -0,0 +1,200 @@
+package slit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tigrawap/slit/logging"
+)
+
+const (
+	indexStep      = 1000 // offset of every indexStep-th line is remembered
+	indexChunkSize = 1024 * 1024
+)
+
+// lineIndex is a sparse offset->line index, built in background by counting newlines in big chunks,
+// which is much faster than reading file line by line.
+// Line of any offset is resolved from the nearest checkpoint, reading at most indexStep lines
+type lineIndex struct {
+	lock        sync.RWMutex
+	reader      *os.File
+	checkpoints []Offset // checkpoints[i] is offset of line i*indexStep
+	scanned     Offset   // number of bytes scanned so far
+	lines       LineNo   // number of newlines in scanned bytes
+	generation  int      // incremented on reset, scan results of previous generation are dropped
+}
+
+func newLineIndex(reader *os.File) *lineIndex {
+	return &lineIndex{
+		reader:      reader,
+		checkpoints: []Offset{0},
+	}
+}
+
+func (idx *lineIndex) reset() {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.checkpoints = []Offset{0}
+	idx.scanned = 0
+	idx.lines = 0
+	idx.generation++
+}
+
+// build scans file until context is done, waiting for new data once reached the end
+func (idx *lineIndex) build(ctx context.Context) {
+	defer logging.Timeit("Indexing")()
+	buf := make([]byte, indexChunkSize)
+	for {
+		idx.lock.RLock()
+		scanned, lines, generation := idx.scanned, idx.lines, idx.generation
+		idx.lock.RUnlock()
+
+		n, err := idx.reader.ReadAt(buf, int64(scanned))
+		if err != nil && err != io.EOF {
+			logging.Debug("Error indexing file:", err)
+		}
+		var newCheckpoints []Offset
+		chunk := buf[:n]
+		for i := bytes.IndexByte(chunk, '\n'); i != -1; i = bytes.IndexByte(chunk, '\n') {
+			lines++
+			scanned += Offset(i + 1)
+			chunk = chunk[i+1:]
+			if lines%indexStep == 0 {
+				newCheckpoints = append(newCheckpoints, scanned)
+			}
+		}
+		scanned += Offset(len(chunk))
+
+		idx.lock.Lock()
+		if idx.generation == generation {
+			idx.checkpoints = append(idx.checkpoints, newCheckpoints...)
+			idx.scanned, idx.lines = scanned, lines
+		}
+		idx.lock.Unlock()
+
+		if n == len(buf) {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// total returns number of lines in scanned part of the file, counting incomplete last line as well
+func (idx *lineIndex) total() LineNo {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	if idx.scanned == 0 {
+		return 0
+	}
+	b := make([]byte, 1)
+	if _, err := idx.reader.ReadAt(b, int64(idx.scanned-1)); err == nil && b[0] != '\n' {
+		return idx.lines + 1
+	}
+	return idx.lines
+}
+
+// isComplete returns true if whole file of given size is scanned
+func (idx *lineIndex) isComplete(size Offset) bool {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	return idx.scanned >= size
+}
+
+// lineAt returns number of the line containing offset, false if offset is not scanned yet
+func (idx *lineIndex) lineAt(offset Offset) (LineNo, bool) {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	if offset > idx.scanned || offset < 0 {
+		return POS_UNKNOWN, false
+	}
+	i := sort.Search(len(idx.checkpoints), func(i int) bool { return idx.checkpoints[i] > offset }) - 1
+	line := LineNo(i * indexStep)
+	from := idx.checkpoints[i]
+	buf := make([]byte, 64*1024)
+	for from < offset {
+		size := int(offset - from)
+		if size > len(buf) {
+			size = len(buf)
+		}
+		n, err := idx.reader.ReadAt(buf[:size], int64(from))
+		line += LineNo(bytes.Count(buf[:n], []byte{'\n'}))
+		from += Offset(n)
+		if err != nil {
+			break
+		}
+	}
+	return line, true
+}
+
+// lineOffset returns offset of the line start, false if line is not scanned yet
+func (idx *lineIndex) lineOffset(line LineNo) (Offset, bool) {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	if line > idx.lines || line < 0 {
+		return 0, false
+	}
+	i := int(line / indexStep)
+	from := idx.checkpoints[i]
+	left := line - LineNo(i*indexStep)
+	buf := make([]byte, 64*1024)
+	for left > 0 {
+		n, err := idx.reader.ReadAt(buf, int64(from))
+		chunk := buf[:n]
+		for left > 0 {
+			j := bytes.IndexByte(chunk, '\n')
+			if j == -1 {
+				break
+			}
+			left--
+			chunk = chunk[j+1:]
+		}
+		from += Offset(n - len(chunk))
+		if err != nil && left > 0 {
+			return 0, false
+		}
+	}
+	return from, true
+}
+
+// waitLine waits until index reaches given line or the end of file
+func (idx *lineIndex) waitLine(ctx context.Context, line LineNo, size func() Offset) {
+	for {
+		idx.lock.RLock()
+		reached := idx.lines >= line || idx.scanned >= size()
+		idx.lock.RUnlock()
+		if reached {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// estimateOffset returns offset of the line start, exact one if line is scanned already,
+// otherwise it is extrapolated from average line length in scanned part
+func (idx *lineIndex) estimateOffset(line LineNo) (offset Offset, exact bool) {
+	if offset, ok := idx.lineOffset(line); ok {
+		return offset, true
+	}
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	if idx.lines == 0 {
+		return idx.scanned, false
+	}
+	return Offset(float64(idx.scanned) / float64(idx.lines) * float64(line)), false
+}
@@ -0,0 +1,192 @@
+package slit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nsf/termbox-go"
+	"github.com/tigrawap/slit/filters"
+)
+
+// matchCounter counts matches of current search over the whole file in background,
+// number of matches is kept per chunk, so index of current match requires scanning one chunk only
+type matchCounter struct {
+	lock     sync.Mutex
+	cancel   context.CancelFunc
+	wake     chan struct{}
+	run      int // incremented on every restart, so stale counting goroutine does not touch new counts
+	active   bool
+	counts   []int  // matches in every complete chunk counted so far
+	partial  int    // matches in last incomplete chunk
+	complete bool   // whole file counted, until more data arrives
+	current  Offset // offset of the match user jumped to, -1 if none
+	index    int    // number of current match, 0 if not known yet
+}
+
+// restart drops previous counts and starts counting matches of searchFunc, nil searchFunc just stops counting
+func (m *matchCounter) restart(ctx context.Context, v *viewer, searchFunc filters.SearchFunc) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	m.run++
+	m.counts, m.partial, m.complete = nil, 0, false
+	m.current, m.index = -1, 0
+	m.active = searchFunc != nil
+	if !m.active {
+		return
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.wake = make(chan struct{}, 1)
+	// chain is taken once, counting is restarted on every change of it
+	chain := v.fetcher.readingChain()
+	go m.count(ctx, v, chain, func(l Line) bool { return searchFunc(l.Str.Runes) != nil }, m.run, m.wake)
+}
+
+// setCurrent remembers match user jumped to, its index is resolved once its chunk is counted
+func (m *matchCounter) setCurrent(offset Offset) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.active {
+		return
+	}
+	m.current, m.index = offset, 0
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *matchCounter) count(ctx context.Context, v *viewer, chain filterChain, match func(Line) bool, run int,
+	wake <-chan struct{}) {
+	f := v.fetcher
+	generation := f.getGeneration()
+	lastUpdate := time.Time{}
+	update := func(force bool) {
+		if !force && time.Since(lastUpdate) < 200*time.Millisecond {
+			return
+		}
+		lastUpdate = time.Now()
+		go termbox.Interrupt()
+		select {
+		case requestMatchesUpdate <- v:
+		case <-ctx.Done():
+		}
+	}
+	countChunk := func(chunk searchChunk, size Offset) (n int) {
+		f.scanChunk(ctx, chain, chunk, size, func(l Line) bool {
+			if match(l) {
+				n++
+			}
+			return true
+		})
+		return
+	}
+	// locate resolves index of current match, once all chunks up to it are counted
+	locate := func(size Offset, counted int) {
+		m.lock.Lock()
+		current, index := m.current, m.index
+		m.lock.Unlock()
+		c := int(current / searchChunkSize)
+		if current < 0 || index != 0 || c > counted {
+			return
+		}
+		index = countChunk(searchChunk{Offset(c) * searchChunkSize, current + 1}, size)
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		if m.run != run || m.current != current || ctx.Err() != nil {
+			return
+		}
+		for i := 0; i < c; i++ {
+			index += m.counts[i]
+		}
+		m.index = index
+	}
+	for {
+		size := f.size()
+		m.lock.Lock()
+		if m.run != run {
+			m.lock.Unlock()
+			return
+		}
+		if g := f.getGeneration(); g != generation { // file was truncated
+			generation = g
+			m.counts, m.partial, m.current, m.index = nil, 0, -1, 0
+		}
+		i := len(m.counts)
+		m.lock.Unlock()
+		for ; Offset(i)*searchChunkSize < size; i++ {
+			chunk := searchChunk{Offset(i) * searchChunkSize, Offset(i+1) * searchChunkSize}
+			n := countChunk(chunk, size)
+			m.lock.Lock()
+			if m.run != run || ctx.Err() != nil {
+				m.lock.Unlock()
+				return
+			}
+			m.complete = false
+			counted := len(m.counts)
+			if chunk.to <= size {
+				m.counts = append(m.counts, n)
+				m.partial = 0
+				counted++
+			} else {
+				m.partial = n
+			}
+			m.lock.Unlock()
+			locate(size, counted)
+			update(false)
+		}
+		m.lock.Lock()
+		m.complete = true
+		counted := len(m.counts)
+		m.lock.Unlock()
+		locate(size, counted)
+		update(true)
+
+		for size == f.size() && f.getGeneration() == generation {
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+				locate(size, counted)
+				update(true)
+			case <-time.After(500 * time.Millisecond):
+			}
+		}
+	}
+}
+
+// status returns text for status bar, empty if there is no search
+func (m *matchCounter) status() string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if !m.active {
+		return ""
+	}
+	total := m.partial
+	for _, n := range m.counts {
+		total += n
+	}
+	totalStr := formatCount(total)
+	if !m.complete {
+		totalStr += "+"
+	}
+	if m.current >= 0 && m.index > 0 {
+		return fmt.Sprintf("match %s of %s", formatCount(m.index), totalStr)
+	}
+	return fmt.Sprintf("%s matches", totalStr)
+}
+
+// formatCount formats number with thousands separated by commas
+func formatCount(n int) string {
+	str := strconv.Itoa(n)
+	for i := len(str) - 3; i > 0; i -= 3 {
+		str = str[:i] + "," + str[i:]
+	}
+	return str
+}
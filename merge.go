@@ -0,0 +1,244 @@
+package slit
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tigrawap/slit/ansi"
+	"github.com/tigrawap/slit/timestamps"
+)
+
+var mergeColors = []ansi.Color{
+	ansi.ColorGreen,
+	ansi.ColorYellow,
+	ansi.ColorBlue,
+	ansi.ColorMagenta,
+	ansi.ColorCyan,
+	ansi.ColorRed,
+}
+
+// How long merge waits for new lines of following source before considering it idle
+const mergeIdleTimeout = 100 * time.Millisecond
+
+type mergeSource struct {
+	fetcher *Fetcher
+	index   int // index of the source in labels
+	lines   chan Line
+	head    *Line
+	ts      time.Time // timestamp of head, lines without one inherit it from previous line
+	parser  timestamps.Parser
+	idle    bool
+	done    bool
+	follow  bool
+}
+
+// NewMerged returns Slit with lines of all given instances interleaved in timestamp order,
+// like sort -m does. Colored name of the source is shown before every line, it is not a part of the line.
+// Merged instance owns its sources, they will be shut down together with it. Sources set to follow
+// are waited for new lines once their end is reached
+func NewMerged(sources []*Slit) (*Slit, error) {
+	ch := make(chan string)
+	s, err := NewFromStream(ch)
+	if err != nil {
+		return nil, err
+	}
+	s.name = "merged"
+	s.sources = sources
+	s.labels = &lineLabels{names: make([]ansi.Astring, len(sources))}
+
+	labelWidth := 0
+	for _, source := range sources {
+		if len(source.name) > labelWidth {
+			labelWidth = len(source.name)
+		}
+	}
+	merged := make([]*mergeSource, len(sources))
+	for i, source := range sources {
+		source.Init()
+		source.fetcher.filters = nil // filters are applied on merged stream
+		color := mergeColors[i%len(mergeColors)]
+		s.labels.names[i] = ansi.NewAstring([]byte(
+			fmt.Sprintf("\x1b[%dm%-*s\x1b[0m ", ansi.FgColor(color), labelWidth, filepath.Base(source.name))))
+		merged[i] = &mergeSource{
+			fetcher: source.fetcher,
+			index:   i,
+			lines:   make(chan Line, 500),
+			follow:  source.follow,
+		}
+	}
+
+	for _, source := range merged {
+		s.wg.Add(1)
+		go func(source *mergeSource) {
+			source.pump(s.ctx)
+			s.wg.Done()
+		}(source)
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(ch)
+		merge(s.ctx, merged, ch, s.labels)
+	}()
+	return s, nil
+}
+
+// pump reads lines of the source, when following waits for new ones after reaching the end
+func (m *mergeSource) pump(ctx context.Context) {
+	defer close(m.lines)
+	var pending *Line // last read line, might be incomplete yet
+	var sent *Pos
+	for {
+		from := Pos{0, 0}
+		if sent != nil {
+			from = *sent
+		}
+		for l := range m.fetcher.Get(ctx, from) {
+			if sent != nil && l.Offset <= sent.Offset {
+				continue
+			}
+			if pending != nil {
+				if !m.send(ctx, *pending) {
+					return
+				}
+				sent = &pending.Pos
+			}
+			l := l
+			pending = &l
+		}
+		if pending != nil && (!m.follow || m.fetcher.endsWithNewline()) {
+			if !m.send(ctx, *pending) {
+				return
+			}
+			sent = &pending.Pos
+			pending = nil
+		}
+		if !m.follow {
+			return
+		}
+		pending = nil
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func (m *mergeSource) send(ctx context.Context, l Line) bool {
+	select {
+	case m.lines <- l:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// fill receives next line of the source. When following, source is waited only for a while
+// and marked as idle if it has nothing new, after that it is only checked without waiting
+func (m *mergeSource) fill(ctx context.Context) {
+	var timeout <-chan time.Time
+	if m.follow {
+		if m.idle {
+			timeout = time.After(0)
+		} else {
+			timeout = time.After(mergeIdleTimeout)
+		}
+	}
+	select {
+	case l, ok := <-m.lines:
+		if !ok {
+			m.done = true
+			return
+		}
+		m.head = &l
+		m.idle = false
+		if ts, ok := m.parser.Parse(string(l.Str.Runes)); ok {
+			m.ts = ts
+		}
+	case <-timeout:
+		m.idle = true
+	case <-ctx.Done():
+	}
+}
+
+// merge is a k-way merge of sources, line with the earliest timestamp is written first.
+// Source of every written line is added to labels
+func merge(ctx context.Context, sources []*mergeSource, out chan<- string, labels *lineLabels) {
+	var offset Offset
+	for {
+		var next *mergeSource
+		finished := true
+		for _, source := range sources {
+			if source.head == nil && !source.done {
+				source.fill(ctx)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if !source.done {
+				finished = false
+			}
+			if source.head == nil {
+				continue
+			}
+			if next == nil || source.ts.Before(next.ts) {
+				next = source
+			}
+		}
+		if next == nil {
+			if finished {
+				return
+			}
+			select { // nothing new, all sources are idle
+			case <-time.After(mergeIdleTimeout):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		line := string(next.head.Str.Bytes())
+		next.head = nil
+		labels.add(offset, next.index)
+		select {
+		case out <- line:
+			offset += Offset(len(line) + 1) // stream writes lines with newline
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// lineLabels keeps sources of merged lines by their offsets, line is only added if its source
+// differs from source of the previous line
+type lineLabels struct {
+	names   []ansi.Astring // colored names of sources
+	lock    sync.RWMutex
+	offsets []Offset
+	sources []int
+}
+
+func (l *lineLabels) add(offset Offset, source int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if n := len(l.sources); n != 0 && l.sources[n-1] == source {
+		return
+	}
+	l.offsets = append(l.offsets, offset)
+	l.sources = append(l.sources, source)
+}
+
+// at returns label of line starting at offset
+func (l *lineLabels) at(offset Offset) ansi.Astring {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	i := sort.Search(len(l.offsets), func(i int) bool { return l.offsets[i] > offset }) - 1
+	if i < 0 {
+		return ansi.Astring{}
+	}
+	return l.names[l.sources[i]]
+}
@@ -0,0 +1,120 @@
+package slit
+
+import (
+	"fmt"
+
+	"github.com/nsf/termbox-go"
+	"github.com/tigrawap/slit/filters"
+)
+
+const presetPickerHelp = "Presets: Enter load, a stack onto current filters, s save current filters, Esc close"
+
+// presetPicker is a panel listing filter presets saved in filters.PresetsDir
+type presetPicker struct {
+	v        *viewer
+	names    []string
+	selected int
+}
+
+func (p *presetPicker) processKey(ev termbox.Event) (a action) {
+	if ev.Ch != 0 {
+		switch ev.Ch {
+		case 'j':
+			p.move(+1)
+		case 'k':
+			p.move(-1)
+		case 'a':
+			return p.load(true)
+		case 's':
+			p.v.focus = &p.v.info
+			p.v.info.reset(ibModeSavePreset)
+			if p.selected < len(p.names) {
+				p.v.info.setInput(p.names[p.selected])
+			}
+			return
+		case 'q', 'P':
+			return p.close()
+		}
+	} else {
+		switch ev.Key {
+		case termbox.KeyArrowDown:
+			p.move(+1)
+		case termbox.KeyArrowUp:
+			p.move(-1)
+		case termbox.KeyEnter:
+			return p.load(false)
+		case termbox.KeyEsc:
+			return p.close()
+		}
+	}
+	p.v.draw()
+	return
+}
+
+func (p *presetPicker) open() {
+	names, err := filters.ListPresets()
+	if err != nil {
+		p.v.info.setMessage(ibMessage{str: "Err:" + err.Error(), color: termbox.ColorRed})
+		p.v.draw()
+		return
+	}
+	p.names = names
+	p.v.focus = p
+	p.move(0)
+	p.v.draw()
+}
+
+func (p *presetPicker) close() action {
+	p.v.focus = p.v
+	p.v.draw()
+	return ACTION_RESET_FOCUS
+}
+
+func (p *presetPicker) move(direction int) {
+	p.selected += direction
+	if p.selected >= len(p.names) {
+		p.selected = len(p.names) - 1
+	}
+	if p.selected < 0 {
+		p.selected = 0
+	}
+}
+
+// load replaces filters chain with selected preset, or appends preset to it when stacking
+func (p *presetPicker) load(stack bool) action {
+	if p.selected >= len(p.names) {
+		return NO_ACTION
+	}
+	v := p.v
+	name := p.names[p.selected]
+	preset, err := filters.LoadPreset(name)
+	if err != nil {
+		v.showSearchError(err)
+		return p.close()
+	}
+	var chain []*filters.Filter
+	if stack {
+		chain = append(chain, v.fetcher.snapshotChain().filters...)
+	}
+	chain = append(chain, preset...)
+	v.fetcher.setFilters(chain)
+	v.onFiltersChange()
+	v.info.setMessage(ibMessage{str: fmt.Sprintf("Loaded preset %s, %d filters", name, len(preset)), color: termbox.ColorGreen})
+	return p.close()
+}
+
+func (p *presetPicker) draw() {
+	rows := make([]panelRow, len(p.names))
+	for i, name := range p.names {
+		rows[i] = panelRow{text: name, fg: termbox.ColorDefault}
+	}
+	drawPanel(p.v, presetPickerHelp, "No presets saved yet", rows, p.selected)
+}
+
+func (v *viewer) savePreset(name string) {
+	if err := filters.SavePreset(name, v.fetcher.snapshotChain().filters); err != nil {
+		v.info.setMessage(ibMessage{str: "Err:" + err.Error(), color: termbox.ColorRed})
+		return
+	}
+	v.info.setMessage(ibMessage{str: fmt.Sprintf("Filters saved as %s", name), color: termbox.ColorGreen})
+}
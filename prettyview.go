@@ -0,0 +1,109 @@
+package slit
+
+import (
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+	"github.com/tigrawap/slit/ansi"
+	"github.com/tigrawap/slit/structured"
+)
+
+const prettyViewHelp = "JSON: j/k scroll, f/b page, g/G start/end, Esc close"
+
+// prettyView shows current JSON line indented on the whole screen
+type prettyView struct {
+	v      *viewer
+	rows   []ansi.Astring
+	offset int
+}
+
+func (p *prettyView) processKey(ev termbox.Event) (a action) {
+	if ev.Ch != 0 {
+		switch ev.Ch {
+		case 'j':
+			p.scroll(+1)
+		case 'k':
+			p.scroll(-1)
+		case 'f', ' ':
+			p.scroll(p.pageSize())
+		case 'b':
+			p.scroll(-p.pageSize())
+		case 'g':
+			p.offset = 0
+		case 'G':
+			p.scroll(len(p.rows))
+		case 'q', 'E':
+			return p.close()
+		}
+	} else {
+		switch ev.Key {
+		case termbox.KeyArrowDown, termbox.KeyEnter:
+			p.scroll(+1)
+		case termbox.KeyArrowUp:
+			p.scroll(-1)
+		case termbox.KeyPgdn, termbox.KeySpace:
+			p.scroll(p.pageSize())
+		case termbox.KeyPgup:
+			p.scroll(-p.pageSize())
+		case termbox.KeyEsc:
+			return p.close()
+		}
+	}
+	p.v.draw()
+	return
+}
+
+// open expands line at the top of the screen, every line of multi-line record is expanded separately
+func (p *prettyView) open() {
+	line := p.v.buffer.currentLine()
+	p.rows = p.rows[:0]
+	p.offset = 0
+	parsed := false
+	for _, row := range recordRows(line.Str) {
+		if pretty, ok := structured.Pretty(string(row.Runes)); ok {
+			p.rows = append(p.rows, pretty...)
+			parsed = true
+		} else {
+			p.rows = append(p.rows, row)
+		}
+	}
+	if !parsed {
+		p.v.info.setMessage(ibMessage{str: "Not a JSON line", color: termbox.ColorRed})
+		p.v.draw()
+		return
+	}
+	p.v.focus = p
+	p.v.draw()
+}
+
+func (p *prettyView) close() action {
+	p.v.focus = p.v
+	p.v.draw()
+	return ACTION_RESET_FOCUS
+}
+
+func (p *prettyView) pageSize() int {
+	return p.v.height - 1
+}
+
+func (p *prettyView) scroll(n int) {
+	p.offset += n
+	if last := len(p.rows) - p.pageSize(); p.offset > last {
+		p.offset = last
+	}
+	if p.offset < 0 {
+		p.offset = 0
+	}
+}
+
+func (p *prettyView) draw() {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	drawPanelRow(p.v, 0, prettyViewHelp, termbox.ColorDefault|termbox.AttrBold, termbox.ColorBlue)
+	for y := 1; y < p.v.height && p.offset+y-1 < len(p.rows); y++ {
+		row := p.rows[p.offset+y-1]
+		for i, x := 0, 0; i < len(row.Runes) && x < p.v.width; i++ {
+			fg, bg := ToTermboxAttr(row.Attrs[i])
+			termbox.SetCell(x, y, row.Runes[i], fg, bg)
+			x += runewidth.RuneWidth(row.Runes[i])
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package slit
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/tigrawap/slit/ansi"
+)
+
+// Record not started within that many lines is split, so file without record starts is not read as one record
+const recordMaxLines = 1000
+
+// recordReader groups lines into records. Record is a line matching record start, followed by lines not matching it,
+// i.e. exception logged with its stack trace. Lines of the record are joined by newline
+type recordReader struct {
+	isStart  func([]byte) bool // nil if every line is a record
+	readLine func() ([]byte, Offset, error)
+	next     []byte // line read ahead, which starts next record
+	nextPos  Offset
+	nextErr  error
+	hasNext  bool
+}
+
+func (f *Fetcher) newRecordReader(chain filterChain, readLine func() ([]byte, Offset, error)) *recordReader {
+	return &recordReader{readLine: readLine, isStart: f.recordStartFunc(chain)}
+}
+
+func (r *recordReader) line() ([]byte, Offset, error) {
+	if r.hasNext {
+		r.hasNext = false
+		return r.next, r.nextPos, r.nextErr
+	}
+	return r.readLine()
+}
+
+func (r *recordReader) unread(str []byte, pos Offset, err error) {
+	r.next, r.nextPos, r.nextErr, r.hasNext = str, pos, err, true
+}
+
+// read returns next record, its offset and number of lines in it. Error is io.EOF once the last line is read
+func (r *recordReader) read() ([]byte, Offset, int, error) {
+	str, offset, err := r.line()
+	lines := 1
+	if r.isStart == nil || (len(str) == 0 && err == io.EOF) {
+		return str, offset, lines, err
+	}
+	for err == nil && lines < recordMaxLines {
+		next, nextPos, nextErr := r.line()
+		if len(next) == 0 && nextErr == io.EOF {
+			return str, offset, lines, nextErr
+		}
+		if r.isStart(next) {
+			r.unread(next, nextPos, nextErr)
+			break
+		}
+		str = append(append(str, '\n'), next...)
+		lines++
+		err = nextErr
+	}
+	return str, offset, lines, err
+}
+
+// skipContinuation skips lines up to the next record start, used once reading starts in the middle of the file.
+// Returns number of skipped lines
+func (r *recordReader) skipContinuation() int {
+	if r.isStart == nil {
+		return 0
+	}
+	for skipped := 0; skipped < recordMaxLines; skipped++ {
+		str, pos, err := r.line()
+		if r.isStart(str) || (len(str) == 0 && err == io.EOF) {
+			r.unread(str, pos, err)
+			return skipped
+		}
+		if err != nil {
+			r.unread(nil, pos+Offset(len(str)), io.EOF)
+			return skipped + 1
+		}
+	}
+	return recordMaxLines
+}
+
+// joinRecord joins lines of the record, which were collected in reverse order while reading backwards
+func joinRecord(reversed []PosLine) PosLine {
+	first := reversed[len(reversed)-1]
+	if len(reversed) == 1 {
+		return first
+	}
+	var b []byte
+	for i := len(reversed) - 1; i >= 0; i-- {
+		if i != len(reversed)-1 {
+			b = append(b, '\n')
+		}
+		b = append(b, reversed[i].b...)
+	}
+	return PosLine{b, first.Pos}
+}
+
+// recordAt returns start of the record containing the line starting at offset.
+// Gives up after recordMaxLines lines, returning start of the last line checked
+func (f *Fetcher) recordAt(chain filterChain, offset Offset) Offset {
+	isStart := f.recordStartFunc(chain)
+	if isStart == nil || offset <= 0 {
+		return offset
+	}
+	size := f.size()
+	reader := bufio.NewReader(io.NewSectionReader(f.reader, int64(offset), int64(size-offset)))
+	if str, _ := reader.ReadBytes('\n'); isStart(bytes.TrimSuffix(str, []byte{'\n'})) {
+		return offset
+	}
+	checked := 1
+	end := offset // lines starting before end are not checked yet
+	for end > 0 && checked < recordMaxLines {
+		from := end - fetchBackStep
+		if from < 0 {
+			from = 0
+		}
+		starts, lines := f.linesIn(from, end)
+		if len(starts) == 0 { // line longer than fetchBackStep
+			end = from
+			continue
+		}
+		for i := len(lines) - 1; i >= 0; i-- {
+			checked++
+			if isStart(lines[i]) || starts[i] == 0 || checked == recordMaxLines {
+				return starts[i]
+			}
+		}
+		end = starts[0]
+	}
+	return end
+}
+
+// linesIn returns offsets and contents of lines starting in [from, end), end should be start of a line
+func (f *Fetcher) linesIn(from, end Offset) ([]Offset, [][]byte) {
+	reader := bufio.NewReaderSize(io.NewSectionReader(f.reader, int64(from), int64(end-from)), 64*1024)
+	offset := from
+	if from > 0 {
+		prev := make([]byte, 1)
+		if _, err := f.reader.ReadAt(prev, int64(from-1)); err != nil {
+			return nil, nil
+		}
+		if prev[0] != '\n' { // starts in the middle of the line
+			skipped, err := reader.ReadBytes('\n')
+			if err != nil {
+				return nil, nil
+			}
+			offset += Offset(len(skipped))
+		}
+	}
+	var starts []Offset
+	var lines [][]byte
+	for offset < end {
+		str, err := reader.ReadBytes('\n')
+		if len(str) == 0 {
+			break
+		}
+		starts = append(starts, offset)
+		offset += Offset(len(str))
+		if err == nil {
+			str = str[:len(str)-1]
+		}
+		lines = append(lines, str)
+		if err != nil {
+			break
+		}
+	}
+	return starts, lines
+}
+
+// recordRows splits record into its lines, every one drawn on its own row
+func recordRows(str ansi.Astring) []ansi.Astring {
+	var rows []ansi.Astring
+	start := 0
+	for i, r := range str.Runes {
+		if r == '\n' {
+			rows = append(rows, ansi.Astring{Runes: str.Runes[start:i], Attrs: str.Attrs[start:i]})
+			start = i + 1
+		}
+	}
+	if start == 0 {
+		return []ansi.Astring{str}
+	}
+	return append(rows, ansi.Astring{Runes: str.Runes[start:], Attrs: str.Attrs[start:]})
+}
@@ -1,6 +1,8 @@
 package runes
 
 import (
+	"unicode"
+
 	"github.com/tigrawap/slit/logging"
 )
 
@@ -35,6 +37,49 @@ func Index(runestack, sub []rune) int {
 	return -1
 }
 
+// IndexFold is case-insensitive Index, runes are compared under Unicode simple case folding
+func IndexFold(runestack, sub []rune) int {
+	for i := 0; i < len(runestack); i++ {
+		found := true
+		if len(runestack[i:]) < len(sub) {
+			return -1
+		}
+		for j := 0; j < len(sub); j++ {
+			if !EqualFold(runestack[i+j], sub[j]) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return i
+		}
+	}
+	return -1
+}
+
+// EqualFold reports whether runes are equal under Unicode simple case folding
+func EqualFold(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for f := unicode.SimpleFold(a); f != a; f = unicode.SimpleFold(f) {
+		if f == b {
+			return true
+		}
+	}
+	return false
+}
+
+// HasUpper reports whether any of runes is uppercase
+func HasUpper(runes []rune) bool {
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
 func IndexRune(runestack []rune, sub rune) int {
 	for i := 0; i < len(runestack); i++ {
 		if runestack[i] == sub {
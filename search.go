@@ -0,0 +1,185 @@
+package slit
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"runtime"
+	"time"
+)
+
+const searchChunkSize = 1024 * 1024
+
+// searchProgress is called periodically by goroutine running the search, with fraction of data already scanned
+type searchProgress func(done float64)
+
+type searchChunk struct {
+	from, to Offset // chunk holds lines starting in [from, to)
+}
+
+type chunkResult struct {
+	chunk int
+	pos   Pos
+	found bool
+}
+
+// searchParallel splits file into chunks, which are scanned on all cores. Chunks are ordered by distance from
+// position search starts at, nearest match is returned once all chunks before it are scanned.
+// Fetcher is not locked while scanning, lines are read with independent readers and filtered by snapshot of the chain.
+// Forward search starts with the line at or after from.Offset, backward one with the line containing it
+func (f *Fetcher) searchParallel(ctx context.Context, from Pos, forward bool, match func(Line) bool, progress searchProgress) Pos {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	size := f.size()
+	if from.Offset >= size {
+		if forward {
+			return POS_NOT_FOUND
+		}
+		from.Offset = size - 1
+	}
+	if from.Offset < 0 {
+		return POS_NOT_FOUND
+	}
+	chunks := splitChunks(from.Offset, size, forward)
+	chain := f.readingChain()
+
+	jobs := make(chan int)
+	results := make(chan chunkResult)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		go func() {
+			for i := range jobs {
+				pos, found := f.searchChunk(ctx, chain, chunks[i], size, forward, match)
+				select {
+				case results <- chunkResult{i, pos, found}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range chunks {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	done := make([]*chunkResult, len(chunks))
+	next := 0
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for next < len(chunks) {
+		select {
+		case r := <-results:
+			done[r.chunk] = &r
+			for next < len(chunks) && done[next] != nil {
+				if done[next].found {
+					return done[next].pos
+				}
+				next++
+			}
+		case <-ticker.C:
+			if progress != nil {
+				progress(float64(next) / float64(len(chunks)))
+			}
+		case <-ctx.Done():
+			return POS_NOT_FOUND
+		}
+	}
+	return POS_NOT_FOUND
+}
+
+// splitChunks returns chunks ordered in search direction
+func splitChunks(from, size Offset, forward bool) []searchChunk {
+	var chunks []searchChunk
+	if forward {
+		for start := from; start < size; start += searchChunkSize {
+			chunks = append(chunks, searchChunk{start, start + searchChunkSize})
+		}
+		return chunks
+	}
+	for end := from + 1; end > 0; end -= searchChunkSize {
+		start := end - searchChunkSize
+		if start < 0 {
+			start = 0
+		}
+		chunks = append(chunks, searchChunk{start, end})
+	}
+	return chunks
+}
+
+// searchChunk returns first matching line of the chunk when searching forward, last one otherwise
+func (f *Fetcher) searchChunk(ctx context.Context, chain filterChain, chunk searchChunk, size Offset, forward bool,
+	match func(Line) bool) (Pos, bool) {
+	result, found := POS_NOT_FOUND, false
+	f.scanChunk(ctx, chain, chunk, size, func(l Line) bool {
+		if match(l) {
+			result, found = l.Pos, true
+			return !forward
+		}
+		return true
+	})
+	if ctx.Err() != nil {
+		return POS_NOT_FOUND, false
+	}
+	return result, found
+}
+
+// scanChunk calls fn for every line (or record) starting in the chunk and not excluded by chain, until fn returns false
+func (f *Fetcher) scanChunk(ctx context.Context, chain filterChain, chunk searchChunk, size Offset, fn func(Line) bool) {
+	offset := chunk.from
+	if offset > 0 {
+		prev := make([]byte, 1)
+		if _, err := f.reader.ReadAt(prev, int64(offset-1)); err != nil {
+			return
+		}
+		if prev[0] != '\n' { // chunk starts in the middle of the line, it belongs to previous chunk
+			reader := bufio.NewReaderSize(io.NewSectionReader(f.reader, int64(offset), int64(size-offset)), 64*1024)
+			skipped, err := reader.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			offset += Offset(len(skipped))
+		}
+	}
+	reader := bufio.NewReaderSize(io.NewSectionReader(f.reader, int64(offset), int64(size-offset)), 64*1024)
+	line := f.resolveLine(offset)
+	records := f.newRecordReader(chain, func() ([]byte, Offset, error) {
+		str, err := reader.ReadBytes('\n')
+		pos := offset
+		offset += Offset(len(str))
+		if err == nil {
+			str = str[:len(str)-1]
+		}
+		return str, pos, err
+	})
+	if offset > 0 { // record started before the chunk belongs to previous chunk
+		skipped := records.skipContinuation()
+		if line != POS_UNKNOWN {
+			line += LineNo(skipped)
+		}
+	}
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		str, pos, lines, err := records.read()
+		if len(str) == 0 && err != nil || pos >= chunk.to {
+			return
+		}
+		l := chain.filteredLine(PosLine{str, Pos{line, pos}})
+		if l.Pos.Line != POS_FILTERED_OUT && !fn(l) {
+			return
+		}
+		if line != POS_UNKNOWN {
+			line += LineNo(lines)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -12,12 +12,14 @@ import (
 	//_ "net/http/pprof"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sync"
 	"syscall"
 
 	"github.com/nsf/termbox-go"
 	"github.com/tigrawap/slit/filters"
 	"github.com/tigrawap/slit/logging"
+	"github.com/tigrawap/slit/structured"
 	"github.com/tigrawap/slit/utils"
 )
 
@@ -28,7 +30,7 @@ func init() {
 		slitdir = filepath.Join(utils.GetHomeDir(), ".slit")
 	}
 	config.historyPath = filepath.Join(slitdir, "history")
-	config.stdinFinished = make(chan struct{})
+	filters.PresetsDir = filepath.Join(slitdir, "filters")
 
 	config.filterOutput = os.Getenv("SLIT_FILTER_OUTPUT_DIR")
 	//go func() {
@@ -37,27 +39,13 @@ func init() {
 }
 
 type Config struct {
-	outPath       string
-	historyPath   string
-	stdin         bool
-	stdinFinished chan struct{}
-	follow        bool
-	keepChars     int
-	filterOutput  string
-	initFilters   []*filters.Filter
+	outPath      string
+	historyPath  string
+	filterOutput string
 }
 
 var config Config
 
-func (c *Config) isStdinRead() bool {
-	select {
-	case <-c.stdinFinished:
-		return true
-	default:
-		return false
-	}
-}
-
 // Slit is a configured instance of the pager, ready to be displayed
 type Slit struct {
 	wg          sync.WaitGroup
@@ -67,6 +55,21 @@ type Slit struct {
 	isCacheFile bool // if true, file will be removed on shutdown
 	fetcher     *Fetcher
 	initialised bool
+	name        string        // shown in infobar when several inputs are displayed in tabs
+	streamDone  chan struct{} // closed once cache file is completely written, nil if not cached
+	sources     []*Slit       // inputs of merged instance, shut down together with it
+	labels      *lineLabels   // sources of lines of merged instance, nil if not merged
+	readErr     chan error    // receives error of reading cached input, shown in infobar. Nil if not cached
+	command     *command      // command writing into cache file, nil if not running one
+	start       startPosition
+
+	follow       bool
+	keepChars    int
+	lineContext  contextLines
+	recordStart  *regexp.Regexp
+	json         *bool // nil to detect JSON lines by input
+	jsonTemplate string
+	initFilters  []*filters.Filter
 }
 
 // Returns input file, original or cache file when reading from stdin
@@ -76,40 +79,90 @@ func (s *Slit) GetFile() *os.File { return s.file }
 func (s *Slit) SetOutPath(path string) { config.outPath = path }
 
 // Set whether to follow file/stdin
-func (s *Slit) SetFollow(b bool) { config.follow = b }
+func (s *Slit) SetFollow(b bool) { s.follow = b }
 
 // Set initial num of chars kept during horizontal scrolling
-func (s *Slit) SetKeepChars(i int) { config.keepChars = i }
+func (s *Slit) SetKeepChars(i int) { s.keepChars = i }
+
+// Set number of lines shown before and after every line included by filters
+func (s *Slit) SetContext(before, after int) { s.lineContext = contextLines{before, after} }
+
+// Set regex matching first line of multi-line record, following lines not matching it belong to the same record
+func (s *Slit) SetRecordStart(re *regexp.Regexp) { s.recordStart = re }
+
+// DefaultJSONTemplate shows time, level and message of JSON line, followed by the rest of keys
+const DefaultJSONTemplate = structured.DefaultTemplate
+
+// Set whether JSON lines are rendered with template, by default it is detected by input
+func (s *Slit) SetJSON(b bool) { s.json = &b }
+
+// Set template of JSON lines rendering, see structured.Template
+func (s *Slit) SetJSONTemplate(t string) { s.jsonTemplate = t }
 
 // Set initial filters
-func (s *Slit) SetFilters(f []*filters.Filter) { config.initFilters = f }
+func (s *Slit) SetFilters(f []*filters.Filter) { s.initFilters = f }
 
 // Invoke the Slit UI
 func (s *Slit) Init() {
 	s.fetcher = newFetcher(s.file, s.ctx)
-	s.fetcher.filters = config.initFilters
+	s.fetcher.filters = s.copyInitFilters()
+	s.fetcher.lineContext = s.lineContext
+	s.fetcher.recordStart = s.recordStart
+	s.fetcher.streamDone = s.streamDone
+	s.fetcher.labels = s.labels
 	s.initialised = true
 }
 
 // Invoke the Slit UI
 func (s *Slit) Display() {
+	DisplayTabs(s)
+}
+
+// DisplayTabs invokes the Slit UI for several instances at once, each one in its own tab.
+// Every tab keeps its own filters, position and highlights
+func DisplayTabs(slits ...*Slit) {
+	viewers := make([]*viewer, len(slits))
+	for i, s := range slits {
+		viewers[i] = s.newViewer()
+	}
+	termGui(viewers)
+}
+
+func (s *Slit) newViewer() *viewer {
 	if !s.initialised {
 		s.Init()
 	}
 
 	s.file.Seek(0, io.SeekStart)
-	//s.fetcher = newFetcher(s.file, s.ctx)
 	s.fetcher.lock.Lock()
-	s.fetcher.filters = config.initFilters
+	s.fetcher.filters = s.copyInitFilters()
+	s.fetcher.lineContext = s.lineContext
+	s.fetcher.recordStart = s.recordStart
 	s.fetcher.lock.Unlock()
 	s.fetcher.seek(0)
 	s.initialised = true
-	v := &viewer{
-		fetcher:   s.fetcher,
-		ctx:       s.ctx,
-		keepChars: config.keepChars,
+	jsonTemplate := s.jsonTemplate
+	if jsonTemplate == "" {
+		jsonTemplate = DefaultJSONTemplate
 	}
-	v.termGui()
+	return &viewer{
+		fetcher:      s.fetcher,
+		ctx:          s.ctx,
+		keepChars:    s.keepChars,
+		followed:     s.follow,
+		name:         s.name,
+		command:      s.command,
+		readErr:      s.readErr,
+		start:        s.start,
+		json:         s.json != nil && *s.json,
+		jsonDetected: s.json != nil,
+		jsonTemplate: structured.ParseTemplate(jsonTemplate),
+	}
+}
+
+// copyInitFilters returns copy of initial filters, so viewer can modify own filters chain
+func (s *Slit) copyInitFilters() []*filters.Filter {
+	return append([]*filters.Filter(nil), s.initFilters...)
 }
 
 // Shutdown and cleanup this pager instance. After instance shutdown,
@@ -117,6 +170,9 @@ func (s *Slit) Display() {
 func (s *Slit) Shutdown() {
 	s.cancel()
 	s.wg.Wait()
+	for _, source := range s.sources {
+		source.Shutdown()
+	}
 	s.file.Close()
 	if s.isCacheFile {
 		os.Remove(s.file.Name())
@@ -130,6 +186,7 @@ func New(f *os.File) *Slit {
 		ctx:    ctx,
 		cancel: cancel,
 		file:   f,
+		name:   filepath.Base(f.Name()),
 	}
 	return s
 }
@@ -147,6 +204,7 @@ func NewFromStream(ch chan string) (*Slit, error) {
 
 	s := New(f)
 	s.isCacheFile = true
+	s.streamDone = make(chan struct{})
 
 	w := bufio.NewWriter(cacheFile)
 	lock := sync.Mutex{}
@@ -162,6 +220,7 @@ func NewFromStream(ch chan string) (*Slit, error) {
 				break
 			}
 		}
+		close(s.streamDone)
 		s.wg.Done()
 	}()
 
@@ -195,8 +254,16 @@ func NewFromStream(ch chan string) (*Slit, error) {
 }
 
 func NewFromStdin() (*Slit, error) {
-	config.stdin = true
+	s, err := newFromReader(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	s.name = "STDIN"
+	return s, nil
+}
 
+// newFromReader caches src in background, decompressing it if needed. src is closed once read
+func newFromReader(src io.ReadCloser) (*Slit, error) {
 	cacheFile, err := mkCacheFile()
 	if err != nil {
 		return nil, err
@@ -208,35 +275,67 @@ func NewFromStdin() (*Slit, error) {
 	}
 
 	s := New(f)
-	if err != nil {
-		return nil, err
-	}
 	s.isCacheFile = true
+	s.streamDone = make(chan struct{})
+	s.readErr = make(chan error, 1)
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			src.Close() // unblocks pending read of pipe
+		case <-s.streamDone:
+		}
+	}()
 	s.wg.Add(1)
 
 	go func() {
-		var err error
+		defer func() {
+			src.Close()
+			close(s.streamDone)
+			s.wg.Done()
+		}()
+		reader, err := DecompressingReader(s.ctx, src)
+		if err != nil {
+			s.readFailed(err)
+			return
+		}
 	copyLoop:
 		for {
 			select {
 			case <-s.ctx.Done():
 				break copyLoop
 			default:
-				_, err = io.CopyN(cacheFile, os.Stdin, 64*1024)
+				_, err = io.CopyN(cacheFile, reader, 64*1024)
+				if err == io.EOF {
+					err = nil
+					break copyLoop
+				}
 				if err != nil {
 					break copyLoop
 				}
 			}
 		}
-		close(config.stdinFinished)
-		s.wg.Done()
+		if closeErr := reader.Close(); err == nil {
+			err = closeErr // decompressing command fails once it is done with corrupted data
+		}
+		if err != nil && s.ctx.Err() == nil {
+			s.readFailed(err)
+		}
 	}()
 
 	return s, nil
 }
 
+// readFailed reports error of reading input, which is cut short then
+func (s *Slit) readFailed(err error) {
+	logging.Debug("Could not read input:", err)
+	s.readErr <- err
+}
+
+// NewFromFilepath opens regular file directly, other kinds of files(named pipes, devices, /proc files)
+// and compressed files are cached and decompressed in background, same as stdin
 func NewFromFilepath(path string) (*Slit, error) {
-	if err := utils.ValidateRegularFile(path); err != nil {
+	seekable, err := utils.ValidateInputFile(path)
+	if err != nil {
 		return nil, err
 	}
 	f, err := os.Open(path)
@@ -246,6 +345,27 @@ func NewFromFilepath(path string) (*Slit, error) {
 	if err != nil {
 		return nil, err
 	}
+	header := make([]byte, maxMagicLength)
+	if seekable {
+		n, _ := f.ReadAt(header, 0)
+		header = header[:n]
+	}
+	c := detectCompression(header)
+	if c != nil {
+		if err := c.available(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if !seekable || c != nil {
+		s, err := newFromReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		s.name = filepath.Base(path)
+		return s, nil
+	}
 	return New(f), nil
 }
 
@@ -281,7 +401,7 @@ FORLOOP:
 			return false
 		case line, isOpen := <-lines:
 			if !isOpen {
-				if config.stdin && !config.isStdinRead() {
+				if s.fetcher.isStreaming() {
 					select {
 					case <-ctx.Done():
 						return false
@@ -0,0 +1,76 @@
+package slit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestNewFromReader(t *testing.T) {
+	content := []byte("first line\nsecond line\nlast line without newline")
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"plain", content},
+		{"gzip", gzipped(content)},
+		{"empty", nil},
+	}
+	for _, test := range tests {
+		s, err := newFromReader(ioutil.NopCloser(bytes.NewReader(test.input)))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		select {
+		case <-s.streamDone:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: stream is not done", test.name)
+		}
+		select {
+		case err := <-s.readErr:
+			t.Errorf("%s: unexpected read error: %v", test.name, err)
+		default:
+		}
+		cached, err := ioutil.ReadFile(s.file.Name())
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		expected := content
+		if test.input == nil {
+			expected = nil
+		}
+		if !bytes.Equal(cached, expected) {
+			t.Errorf("%s: cached %q, expected %q", test.name, cached, expected)
+		}
+		s.Shutdown()
+	}
+}
+
+func TestNewFromReaderTruncated(t *testing.T) {
+	compressed := gzipped(bytes.Repeat([]byte("some line\n"), 1000))
+	s, err := newFromReader(ioutil.NopCloser(bytes.NewReader(compressed[:len(compressed)/2])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Shutdown()
+	select {
+	case <-s.streamDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream is not done")
+	}
+	select {
+	case <-s.readErr:
+	default:
+		t.Error("error of truncated input is not reported")
+	}
+}
@@ -0,0 +1,109 @@
+package slit
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/nsf/termbox-go"
+	"github.com/tigrawap/slit/filters"
+)
+
+type startKind uint8
+
+const (
+	startBeginning startKind = iota
+	startEnd
+	startLine
+	startOffset
+	startSearch
+)
+
+// startPosition is a position view is opened at, before anything is drawn
+type startPosition struct {
+	kind   startKind
+	line   LineNo
+	offset Offset
+	search []rune
+}
+
+// Open view at the end, like G does
+func (s *Slit) SetStartEnd() { s.start = startPosition{kind: startEnd} }
+
+// Open view at given line, lines are numbered from 1
+func (s *Slit) SetStartLine(line int64) {
+	s.start = startPosition{kind: startLine, line: LineNo(line - 1)}
+}
+
+// Open view at the line containing given byte offset
+func (s *Slit) SetStartOffset(offset int64) {
+	s.start = startPosition{kind: startOffset, offset: Offset(offset)}
+}
+
+// Open view at first line matching case-sensitive pattern, pattern is kept as current search
+func (s *Slit) SetStartSearch(pattern string) {
+	s.start = startPosition{kind: startSearch, search: []rune(pattern)}
+}
+
+// navigateToStart moves view to start position
+func (v *viewer) navigateToStart(start startPosition) {
+	switch start.kind {
+	case startEnd:
+		v.navigateEnd()
+	case startLine:
+		v.navigateToPos(v.fetcher.lineStart(v.ctx, start.line))
+	case startOffset:
+		offset, err := v.fetcher.findLine(start.offset)
+		if err == io.EOF {
+			v.navigateEnd()
+			return
+		}
+		v.navigateToPos(Pos{v.fetcher.resolveLine(offset), offset})
+	case startSearch:
+		v.search = start.search
+		v.forwardSearch = true
+		v.recountMatches()
+		v.info.setMessage(ibMessage{str: "Searching...", color: termbox.ColorYellow})
+	}
+}
+
+// searchStart looks for the first match of start search in background, so quitting is not blocked by it
+func (v *viewer) searchStart(ctx context.Context) {
+	searchFunc, err := filters.GetSearchFunc(filters.CaseSensitive, v.start.search)
+	if err != nil {
+		return
+	}
+	pos := v.fetcher.Search(ctx, Pos{0, 0}, searchFunc, nil)
+	if ctx.Err() != nil {
+		return
+	}
+	go termbox.Interrupt()
+	select {
+	case requestStartFound <- startFound{v, pos}:
+	case <-ctx.Done():
+	}
+}
+
+type startFound struct {
+	v   *viewer
+	pos Pos
+}
+
+var requestStartFound = make(chan startFound)
+
+func (v *viewer) onStartFound(pos Pos) {
+	if pos == POS_NOT_FOUND {
+		v.info.setMessage(ibMessage{str: fmt.Sprintf("'%s' not found", string(v.start.search)), color: termbox.ColorRed})
+		v.draw()
+		return
+	}
+	v.info.reset(ibModeStatus)
+	v.matches.setCurrent(pos.Offset)
+	v.navigateToPos(pos)
+}
+
+func (v *viewer) navigateToPos(pos Pos) {
+	v.following = false
+	v.buffer.reset(pos)
+	v.draw()
+}
@@ -0,0 +1,435 @@
+// Package structured parses JSON lines logs and renders them in compact human readable form
+package structured
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tigrawap/slit/ansi"
+)
+
+type valueKind uint8
+
+const (
+	kindString valueKind = iota
+	kindNumber
+	kindBool
+	kindNull
+	kindComposite // object or array, kept as compact JSON
+)
+
+// Field is a key of JSON object with its value. String values are unquoted, others are JSON text
+type Field struct {
+	Key        string
+	Value      string
+	kind       valueKind
+	start, end int    // bytes of the value in the line, quotes of string values excluded
+	raw        string // object or array as in the line, nested fields are looked up in it
+}
+
+// Span returns range of bytes of the value in the parsed line, as written there
+func (f Field) Span() (start, end int) {
+	return f.start, f.end
+}
+
+// Record is JSON object with keys in the order of the line
+type Record []Field
+
+// Get returns value of the first key found, keys are tried in order given
+func (r Record) Get(keys ...string) (Field, bool) {
+	for _, key := range keys {
+		for _, f := range r {
+			if f.Key == key {
+				return f, true
+			}
+		}
+	}
+	return Field{}, false
+}
+
+// Parse parses line as JSON object, false if line is anything else
+func Parse(line string) (Record, bool) {
+	trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+	lead := len(line) - len(trimmed)
+	line = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	if !strings.HasPrefix(line, "{") {
+		return nil, false
+	}
+	reader := strings.NewReader(line)
+	dec := json.NewDecoder(reader)
+	dec.UseNumber()
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, false
+	}
+	var record Record
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		key, ok := t.(string)
+		if !ok {
+			return nil, false
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, false
+		}
+		buffered, _ := io.Copy(ioutil.Discard, dec.Buffered())
+		end := lead + len(line) - reader.Len() - int(buffered)
+		record = append(record, newField(key, raw, end-len(raw)))
+	}
+	if t, err := dec.Token(); err != nil || t != json.Delim('}') {
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false // trailing data
+	}
+	return record, true
+}
+
+// newField returns field of the value found at given offset of the line
+func newField(key string, raw json.RawMessage, offset int) Field {
+	f := Field{Key: key, Value: string(raw), start: offset, end: offset + len(raw)}
+	switch raw[0] {
+	case '"':
+		f.kind = kindString
+		f.start, f.end = f.start+1, f.end-1
+		json.Unmarshal(raw, &f.Value)
+	case '{', '[':
+		f.kind = kindComposite
+		f.raw = string(raw)
+		var compact bytes.Buffer
+		if json.Compact(&compact, raw) == nil {
+			f.Value = compact.String()
+		}
+	case 't', 'f':
+		f.kind = kindBool
+	case 'n':
+		f.kind = kindNull
+	default:
+		f.kind = kindNumber
+	}
+	return f
+}
+
+// ParseLogfmt parses line of key=value pairs, values with spaces are quoted: level=info msg="user logged in".
+// Words without = are skipped, so plain text prefix like timestamp is allowed. False if no pairs found
+func ParseLogfmt(line string) (Record, bool) {
+	var record Record
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+		start := i
+		for i < len(line) && line[i] != '=' && line[i] != ' ' && line[i] != '\t' && line[i] != '"' {
+			i++
+		}
+		if i == len(line) || line[i] != '=' || i == start {
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' { // not a pair, skipping the word
+				i++
+			}
+			continue
+		}
+		key := line[start:i]
+		i++
+		f := Field{Key: key, start: i}
+		if i < len(line) && line[i] == '"' {
+			end := quotedEnd(line[i:])
+			if end == -1 {
+				return nil, false
+			}
+			unquoted, err := strconv.Unquote(line[i : i+end])
+			if err != nil {
+				return nil, false
+			}
+			f.Value = unquoted
+			f.start, f.end = i+1, i+end-1
+			i += end
+		} else {
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+			f.Value, f.end = line[f.start:i], i
+		}
+		f.kind = logfmtKind(f.Value)
+		record = append(record, f)
+	}
+	return record, len(record) != 0
+}
+
+// quotedEnd returns length of quoted string in the beginning of str, -1 if quote is not closed
+func quotedEnd(str string) int {
+	for i := 1; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func logfmtKind(value string) valueKind {
+	switch value {
+	case "true", "false":
+		return kindBool
+	case "null", "nil":
+		return kindNull
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return kindNumber
+	}
+	return kindString
+}
+
+// ParseFields parses line as JSON object, or as logfmt if it is not JSON
+func ParseFields(line string) (Record, bool) {
+	if record, ok := Parse(line); ok {
+		return record, true
+	}
+	return ParseLogfmt(line)
+}
+
+// Lookup returns field by dotted path, i.e. user.id is key "id" of object under key "user".
+// Key containing dots itself is matched as well
+func (r Record) Lookup(path string) (Field, bool) {
+	if f, ok := r.Get(path); ok {
+		return f, true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		parent, ok := r.Get(path[:i])
+		if !ok || parent.kind != kindComposite {
+			continue
+		}
+		if nested, ok := Parse(parent.raw); ok {
+			if f, ok := nested.Lookup(path[i+1:]); ok {
+				f.start, f.end = f.start+parent.start, f.end+parent.start
+				return f, true
+			}
+		}
+	}
+	return Field{}, false
+}
+
+// Detect returns true if most of non-empty lines are JSON objects
+func Detect(lines []string) bool {
+	total, parsed := 0, 0
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		total++
+		if _, ok := Parse(line); ok {
+			parsed++
+		}
+	}
+	return total != 0 && parsed*5 >= total*4
+}
+
+// Pretty returns line as indented JSON with keys colored, false if line is not a JSON object
+func Pretty(line string) ([]ansi.Astring, bool) {
+	if _, ok := Parse(line); !ok {
+		return nil, false
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(strings.TrimSpace(line)), "", "  "); err != nil {
+		return nil, false
+	}
+	lines := strings.Split(indented.String(), "\n")
+	rows := make([]ansi.Astring, len(lines))
+	for i, l := range lines {
+		var b builder
+		indent := len(l) - len(strings.TrimLeft(l, " "))
+		b.append(l[:indent], 0)
+		l = l[indent:]
+		if end := keyEnd(l); end > 0 {
+			b.append(l[:end], keyColor)
+			l = l[end:]
+		}
+		b.append(l, 0)
+		rows[i] = b.Astring
+	}
+	return rows, true
+}
+
+// keyEnd returns length of quoted key in the beginning of indented JSON line, 0 if line does not start with a key
+func keyEnd(l string) int {
+	if !strings.HasPrefix(l, "\"") {
+		return 0
+	}
+	for i := 1; i < len(l); i++ {
+		switch l[i] {
+		case '\\':
+			i++
+		case '"':
+			if strings.HasPrefix(l[i+1:], ":") {
+				return i + 1
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// DefaultTemplate shows time, level and message first, followed by the rest of fields
+const DefaultTemplate = "time|ts|timestamp|@timestamp level|lvl|severity msg|message ..."
+
+// Template describes compact rendering of a record. It is a space separated list of keys, shown as values
+// in the given order, alternative names of the key are separated by |.
+// "..." (or "key=val...") shows all other fields as key=value
+type Template struct {
+	columns [][]string
+	rest    bool
+}
+
+// ParseTemplate parses template string, see Template
+func ParseTemplate(str string) Template {
+	var t Template
+	for _, column := range strings.Fields(str) {
+		if column == "..." || column == "key=val..." {
+			t.rest = true
+			continue
+		}
+		t.columns = append(t.columns, strings.Split(column, "|"))
+	}
+	return t
+}
+
+var (
+	keyColor    = ansi.FgColor(ansi.ColorCyan)
+	timeColor   = ansi.FgColor(ansi.ColorBlue)
+	numberColor = ansi.FgColor(ansi.ColorMagenta)
+	nullColor   = ansi.FgColor(ansi.ColorGray)
+)
+
+// levelColors are matched by prefix of lowercase level
+var levelColors = []struct {
+	prefix string
+	color  uint8
+}{
+	{"err", ansi.FgColor(ansi.ColorRed)},
+	{"fatal", ansi.FgColor(ansi.ColorRed)},
+	{"panic", ansi.FgColor(ansi.ColorRed)},
+	{"crit", ansi.FgColor(ansi.ColorRed)},
+	{"warn", ansi.FgColor(ansi.ColorYellow)},
+	{"info", ansi.FgColor(ansi.ColorGreen)},
+	{"debug", ansi.FgColor(ansi.ColorBlue)},
+	{"trace", ansi.FgColor(ansi.ColorBlue)},
+}
+
+type builder struct {
+	ansi.Astring
+}
+
+func (b *builder) add(str string, fg uint8) {
+	if len(b.Runes) != 0 {
+		b.Runes = append(b.Runes, ' ')
+		b.Attrs = append(b.Attrs, ansi.RuneAttr{})
+	}
+	b.append(str, fg)
+}
+
+func (b *builder) append(str string, fg uint8) {
+	for _, r := range str {
+		b.Runes = append(b.Runes, r)
+		b.Attrs = append(b.Attrs, ansi.RuneAttr{Fg: fg})
+	}
+}
+
+// Render returns record in compact form with keys and values colored
+func (t Template) Render(r Record) ansi.Astring {
+	var b builder
+	used := make(map[string]bool)
+	for _, column := range t.columns {
+		f, ok := r.Get(column...)
+		if !ok {
+			continue
+		}
+		used[f.Key] = true
+		fg := valueColor(f)
+		switch {
+		case hasKey(column, levelKeys):
+			level := strings.ToLower(f.Value)
+			for _, lc := range levelColors {
+				if strings.HasPrefix(level, lc.prefix) {
+					fg = lc.color
+					break
+				}
+			}
+		case hasKey(column, timeKeys):
+			fg = timeColor
+		}
+		b.add(escapeControl(f.Value), fg)
+	}
+	if t.rest {
+		for _, f := range r {
+			if used[f.Key] {
+				continue
+			}
+			b.add(escapeControl(f.Key), keyColor)
+			b.append("=", 0)
+			value := f.Value
+			if f.kind == kindString && (value == "" || strings.ContainsAny(value, " =\"") ||
+				strings.IndexFunc(value, unicode.IsControl) != -1) {
+				value = strconv.Quote(value)
+			}
+			b.append(value, valueColor(f))
+		}
+	}
+	return b.Astring
+}
+
+// escapeControl shows control characters as escape sequences, i.e. \n, so value stays on its line
+func escapeControl(str string) string {
+	if strings.IndexFunc(str, unicode.IsControl) == -1 {
+		return str
+	}
+	var b strings.Builder
+	for _, r := range str {
+		if unicode.IsControl(r) {
+			quoted := strconv.QuoteRune(r)
+			b.WriteString(quoted[1 : len(quoted)-1])
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var (
+	levelKeys = []string{"level", "lvl", "severity", "loglevel", "log.level"}
+	timeKeys  = []string{"time", "ts", "timestamp", "@timestamp", "t", "date"}
+)
+
+// hasKey returns true if one of column keys is one of known names, case-insensitive
+func hasKey(column []string, known []string) bool {
+	for _, key := range column {
+		for _, k := range known {
+			if strings.EqualFold(key, k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func valueColor(f Field) uint8 {
+	switch f.kind {
+	case kindNumber, kindBool:
+		return numberColor
+	case kindNull:
+		return nullColor
+	}
+	return 0
+}
@@ -0,0 +1,25 @@
+package slit
+
+// tabs holds viewers of all opened inputs, only the current one is drawn
+type tabs struct {
+	viewers []*viewer
+	current int
+}
+
+func (t *tabs) active() *viewer {
+	return t.viewers[t.current]
+}
+
+// switchTab moves to the next(+1) or previous(-1) tab, wrapping around
+func (t *tabs) switchTab(direction int) {
+	if len(t.viewers) < 2 {
+		return
+	}
+	t.active().hidden = true
+	t.current = (t.current + direction + len(t.viewers)) % len(t.viewers)
+	v := t.active()
+	v.hidden = false
+	v.resetFocus()
+	v.info.reset(ibModeStatus)
+	v.draw()
+}
@@ -0,0 +1,162 @@
+package slit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tigrawap/slit/logging"
+	"github.com/tigrawap/slit/utils"
+)
+
+const tailPollInterval = 250 * time.Millisecond
+
+// NewFollowingName returns Slit following path by name, like tail -F does.
+// Once file is renamed, removed or recreated, new file is reopened and its data is appended
+// to the same view after marker line, so all of them look like one continuous stream
+func NewFollowingName(path string) (*Slit, error) {
+	if err := utils.ValidateRegularFile(path); err != nil {
+		return nil, err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	cacheFile, err := mkCacheFile()
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+	f, err := os.Open(cacheFile.Name())
+	if err != nil {
+		src.Close()
+		return nil, err
+	}
+
+	s := New(f)
+	s.isCacheFile = true
+	s.name = filepath.Base(path)
+	s.streamDone = make(chan struct{})
+	t := &tailer{
+		path:  path,
+		src:   src,
+		cache: cacheFile,
+	}
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			t.src.Close()
+			close(s.streamDone)
+			s.wg.Done()
+		}()
+		t.run(s.ctx)
+	}()
+	return s, nil
+}
+
+type tailer struct {
+	path     string
+	src      *os.File
+	offset   int64 // read position in src
+	cache    *os.File
+	lastByte byte
+	missing  bool
+}
+
+func (t *tailer) run(ctx context.Context) {
+	buf := make([]byte, 64*1024)
+	for {
+		if !t.copyAll(ctx, buf) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tailPollInterval):
+		}
+		t.checkPath(ctx, buf)
+	}
+}
+
+// copyAll copies all new data, returns false if it was stopped by ctx
+func (t *tailer) copyAll(ctx context.Context, buf []byte) bool {
+	for t.copyAvailable(buf) {
+		if ctx.Err() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// copyAvailable copies one chunk of new data, returns false if nothing to copy
+func (t *tailer) copyAvailable(buf []byte) bool {
+	n, err := t.src.Read(buf)
+	if n > 0 {
+		t.write(buf[:n])
+		t.offset += int64(n)
+		return true
+	}
+	if err != nil && err != io.EOF {
+		logging.Debug("Error reading followed file:", err)
+	}
+	return false
+}
+
+// checkPath detects rotation and truncation of followed file
+func (t *tailer) checkPath(ctx context.Context, buf []byte) {
+	pathStat, err := os.Stat(t.path)
+	if err != nil {
+		if !t.missing {
+			t.missing = true
+			t.marker("was removed, waiting for it to appear again")
+		}
+		return
+	}
+	srcStat, err := t.src.Stat()
+	if err != nil || !os.SameFile(pathStat, srcStat) {
+		src, err := os.Open(t.path)
+		if err != nil {
+			logging.Debug("Could not reopen followed file:", err)
+			return
+		}
+		if !t.copyAll(ctx, buf) { // leftovers written to rotated file
+			src.Close()
+			return
+		}
+		t.src.Close()
+		t.src = src
+		t.offset = 0
+		if t.missing {
+			t.marker("appeared again")
+		} else {
+			t.marker("was rotated, reopened")
+		}
+		t.missing = false
+		return
+	}
+	if pathStat.Size() < t.offset {
+		t.src.Seek(0, io.SeekStart)
+		t.offset = 0
+		t.marker("was truncated")
+	}
+}
+
+func (t *tailer) marker(event string) {
+	prefix := ""
+	if t.lastByte != '\n' && t.lastByte != 0 {
+		prefix = "\n"
+	}
+	t.write([]byte(fmt.Sprintf("%s\x1b[7m--- %s %s at %s ---\x1b[0m\n",
+		prefix, t.path, event, time.Now().Format("2006-01-02 15:04:05"))))
+}
+
+func (t *tailer) write(b []byte) {
+	if _, err := t.cache.Write(b); err != nil {
+		logging.Debug("Error writing cache file:", err)
+		return
+	}
+	t.lastByte = b[len(b)-1]
+}
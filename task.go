@@ -0,0 +1,93 @@
+package slit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/nsf/termbox-go"
+)
+
+// viewerTask is search or jump running in background, so UI stays responsive while huge file is scanned.
+// Only one task runs at a time: starting new one or pressing Esc cancels the running one
+type viewerTask struct {
+	id     int                // identifies task results are delivered for, results of canceled tasks are dropped
+	cancel context.CancelFunc // nil if no task is running
+	wg     sync.WaitGroup
+}
+
+// taskDone carries result of the task, which is applied by UI goroutine
+type taskDone struct {
+	v     *viewer
+	id    int
+	apply func()
+}
+
+type taskProgress struct {
+	v    *viewer
+	id   int
+	done float64
+}
+
+var requestTaskDone = make(chan taskDone)
+var requestTaskProgress = make(chan taskProgress)
+
+// runTask cancels running task and runs fn in background. Func returned by fn is called by UI goroutine,
+// unless task is canceled by then
+func (v *viewer) runTask(fn func(ctx context.Context, progress searchProgress) func()) {
+	v.cancelTask()
+	ctx, cancel := context.WithCancel(v.ctx)
+	v.task.id++
+	v.task.cancel = cancel
+	id := v.task.id
+	progress := func(done float64) {
+		go termbox.Interrupt()
+		select {
+		case requestTaskProgress <- taskProgress{v, id, done}:
+		case <-ctx.Done():
+		}
+	}
+	v.task.wg.Add(1)
+	go func() {
+		defer v.task.wg.Done()
+		apply := fn(ctx, progress)
+		if ctx.Err() != nil {
+			return
+		}
+		go termbox.Interrupt()
+		select {
+		case requestTaskDone <- taskDone{v, id, apply}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// cancelTask cancels running task, false if there is none
+func (v *viewer) cancelTask() bool {
+	if v.task.cancel == nil {
+		return false
+	}
+	v.task.cancel()
+	v.task.cancel = nil
+	return true
+}
+
+// stopTask cancels running task and waits for it to return, so file is not read after it is closed
+func (v *viewer) stopTask() {
+	v.cancelTask()
+	v.task.wg.Wait()
+}
+
+func (v *viewer) onTaskDone(done taskDone) {
+	if done.id != v.task.id || v.task.cancel == nil {
+		return
+	}
+	v.cancelTask() // releases resources of the context
+	done.apply()
+}
+
+func (v *viewer) onTaskProgress(progress taskProgress) {
+	if progress.id != v.task.id || v.task.cancel == nil {
+		return
+	}
+	v.showSearchProgress(progress.done)
+}
@@ -9,6 +9,7 @@ import (
 	"path"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/tigrawap/slit/ansi"
 	"github.com/tigrawap/slit/filters"
 	"github.com/tigrawap/slit/logging"
+	"github.com/tigrawap/slit/structured"
 	"github.com/tigrawap/slit/utils"
 )
 
@@ -35,7 +37,24 @@ type viewer struct {
 	buffer        viewBuffer
 	keepChars     int
 	ctx           context.Context
+	followed      bool // input is followed, set by --follow
 	following     bool
+	name          string // name of the input, shown in infobar when several tabs are opened
+	label         string
+	hidden        bool     // true when viewer is not in the current tab, nothing drawn then
+	command       *command // command being paged, nil if not running one
+	restarting    bool     // command is being restarted
+	readErr       <-chan error
+	start         startPosition
+	matches       matchCounter // counts matches of current search in background
+	task          viewerTask   // search or jump running in background
+	filterManager filterManager
+	presetPicker  presetPicker
+	prettyView    prettyView
+	json          bool // JSON lines are rendered with jsonTemplate
+	jsonDetected  bool // false until it is decided whether input is JSON lines
+	jsonTemplate  structured.Template
+	editedFilter  int // index of filter being edited in filter manager, -1 when adding new one
 }
 
 type action uint
@@ -44,6 +63,8 @@ const (
 	NO_ACTION action = iota
 	ACTION_QUIT
 	ACTION_RESET_FOCUS
+	ACTION_NEXT_TAB
+	ACTION_PREV_TAB
 )
 
 type View interface {
@@ -62,18 +83,18 @@ type Navigator interface {
 func (v *viewer) searchForward() {
 	searchFunc, err := filters.GetSearchFunc(v.info.searchType, v.search)
 	if err != nil {
+		v.showSearchError(err)
 		return
 	}
 	if distance := v.buffer.searchForward(searchFunc); distance != -1 {
-		v.navigate(distance)
+		v.navigateToMatch(distance)
 		return
 	}
-	if pos := v.fetcher.Search(context.TODO(), v.buffer.lastLine().Pos, searchFunc); pos != POS_NOT_FOUND {
-		v.buffer.reset(pos)
-		v.draw()
-	} else {
-		v.info.setMessage(ibMessage{str: fmt.Sprintf("'%s' not found", string(v.search)), color: termbox.ColorRed})
-	}
+	from, search := v.buffer.lastLine().Pos, v.search
+	v.runTask(func(ctx context.Context, progress searchProgress) func() {
+		pos := v.fetcher.Search(ctx, from, searchFunc, progress)
+		return func() { v.onSearchDone(pos, search) }
+	})
 }
 
 func (v *viewer) searchHighlighted() {
@@ -81,32 +102,28 @@ func (v *viewer) searchHighlighted() {
 		v.navigate(distance)
 		return
 	}
-	if pos := v.fetcher.SearchHighlighted(context.TODO(), v.buffer.lastLine().Pos); pos != POS_NOT_FOUND {
-		v.buffer.reset(pos)
-		v.draw()
-	}
+	from := v.buffer.lastLine().Pos
+	v.runTask(func(ctx context.Context, progress searchProgress) func() {
+		pos := v.fetcher.SearchHighlighted(ctx, from, progress)
+		return func() { v.onSearchDone(pos, nil) }
+	})
 }
 
 func (v *viewer) searchBack() {
 	searchFunc, err := filters.GetSearchFunc(v.info.searchType, v.search)
 	if err != nil {
+		v.showSearchError(err)
 		return
 	}
 	if distance := v.buffer.searchBack(searchFunc); distance != -1 {
-		v.navigate(-distance)
+		v.navigateToMatch(-distance)
 		return
 	}
-	fromPos := v.buffer.currentLine().Pos
-	if fromPos.Line > 0 {
-		fromPos.Line--
-	}
-	fromPos.Offset--
-	if pos := v.fetcher.SearchBack(context.TODO(), fromPos, searchFunc); pos != POS_NOT_FOUND {
-		v.buffer.reset(pos)
-		v.draw()
-	} else {
-		v.info.setMessage(ibMessage{str: fmt.Sprintf("'%s' not found", string(v.search)), color: termbox.ColorRed})
-	}
+	from, search := v.searchBackFrom(), v.search
+	v.runTask(func(ctx context.Context, progress searchProgress) func() {
+		pos := v.fetcher.SearchBack(ctx, from, searchFunc, progress)
+		return func() { v.onSearchDone(pos, search) }
+	})
 }
 
 func (v *viewer) searchBackHighlighted() {
@@ -114,15 +131,67 @@ func (v *viewer) searchBackHighlighted() {
 		v.navigate(-distance)
 		return
 	}
+	from := v.searchBackFrom()
+	v.runTask(func(ctx context.Context, progress searchProgress) func() {
+		pos := v.fetcher.SearchBackHighlighted(ctx, from, progress)
+		return func() { v.onSearchDone(pos, nil) }
+	})
+}
+
+// searchBackFrom returns position back-search starts at, the line before the top one
+func (v *viewer) searchBackFrom() Pos {
 	fromPos := v.buffer.currentLine().Pos
 	if fromPos.Line > 0 {
 		fromPos.Line--
 	}
 	fromPos.Offset--
-	if pos := v.fetcher.SearchBackHighlighted(context.TODO(), fromPos); pos != POS_NOT_FOUND {
-		v.buffer.reset(pos)
-		v.draw()
+	return fromPos
+}
+
+// onSearchDone moves view to the match found by background search. Search is nil for highlighted lines,
+// which are not reported if not found
+func (v *viewer) onSearchDone(pos Pos, search []rune) {
+	if pos == POS_NOT_FOUND {
+		if search != nil {
+			v.info.setMessage(ibMessage{str: fmt.Sprintf("'%s' not found", string(search)), color: termbox.ColorRed})
+		} else {
+			v.info.reset(ibModeStatus)
+		}
+		return
+	}
+	v.info.reset(ibModeStatus)
+	v.buffer.reset(pos)
+	if search != nil {
+		v.matches.setCurrent(pos.Offset)
 	}
+	v.draw()
+}
+
+// navigateToMatch moves view to match found in buffer at given distance from top line
+func (v *viewer) navigateToMatch(distance int) {
+	if match, err := v.buffer.getLine(distance); err == nil {
+		v.matches.setCurrent(match.Offset)
+	}
+	v.navigate(distance)
+}
+
+// recountMatches starts counting matches of current search from scratch, once search or filters are changed
+func (v *viewer) recountMatches() {
+	var searchFunc filters.SearchFunc
+	if len(v.search) != 0 {
+		searchFunc, _ = filters.GetSearchFunc(v.info.searchType, v.search)
+	}
+	v.matches.restart(v.ctx, v, searchFunc)
+}
+
+// showSearchError tells user why pattern could not be used
+func (v *viewer) showSearchError(err error) {
+	v.info.setMessage(ibMessage{str: err.Error(), color: termbox.ColorRed})
+}
+
+// showSearchProgress keeps user informed while long search is running
+func (v *viewer) showSearchProgress(done float64) {
+	v.info.setMessage(ibMessage{str: fmt.Sprintf("Searching... %d%%", int(done*100)), color: termbox.ColorYellow})
 }
 
 func (v *viewer) nextSearch(reverse bool) {
@@ -137,25 +206,60 @@ func (v *viewer) nextSearch(reverse bool) {
 }
 
 func (v *viewer) applyFilter(filter *filters.Filter) {
-	v.fetcher.lock.Lock()
-	v.fetcher.filters = append(v.fetcher.filters, filter)
-	v.fetcher.filtersEnabled = true
+	v.fetcher.addFilter(filter)
 	v.buffer.reset(v.buffer.currentLine().Pos)
-	v.fetcher.lock.Unlock()
+	v.recountMatches()
 }
 
 func (v *viewer) addFilter(sub []rune, action filters.FilterAction) {
 	filter, err := filters.NewFilter(sub, action, v.info.searchType)
 	if err != nil {
 		logging.Debug(err)
+		v.showSearchError(err)
+		return
+	}
+	if chain := v.fetcher.snapshotChain().filters; v.editedFilter >= 0 && v.editedFilter < len(chain) {
+		chain = append([]*filters.Filter(nil), chain...)
+		filter.Disabled = chain[v.editedFilter].Disabled
+		filter.Color = chain[v.editedFilter].Color
+		chain[v.editedFilter] = filter
+		v.editedFilter = -1
+		v.fetcher.setFilters(chain)
+		v.onFiltersChange()
+		v.focus = &v.filterManager
 		return
 	}
 	v.applyFilter(filter)
 }
 
+// addTimeRange keeps only lines within time range, typed as SINCE..UNTIL, i.e. 14:00..14:10
+func (v *viewer) addTimeRange(sub []rune) {
+	filter, err := filters.NewFilter(sub, filters.FilterIntersect, filters.Time)
+	if err != nil {
+		v.showSearchError(err)
+		return
+	}
+	v.applyFilter(filter)
+}
+
+// onFiltersChange re-filters view, keeping top line in place as much as possible
+func (v *viewer) onFiltersChange() {
+	v.buffer.reset(v.buffer.currentLine().Pos)
+	v.recountMatches()
+}
+
+// setContext shows given number of lines around every line included by filters
+func (v *viewer) setContext(lineContext contextLines) {
+	v.fetcher.lock.Lock()
+	v.fetcher.lineContext = lineContext
+	v.fetcher.lock.Unlock()
+	v.buffer.reset(v.buffer.currentLine().Pos)
+}
+
 func (v *viewer) switchFilters() {
-	v.fetcher.filtersEnabled = !v.fetcher.filtersEnabled
+	v.fetcher.switchFilters()
 	v.buffer.reset(v.buffer.currentLine().Pos)
+	v.recountMatches()
 	v.draw()
 }
 
@@ -233,76 +337,160 @@ func ToTermboxAttr(attr ansi.RuneAttr) (fg, bg termbox.Attribute) {
 }
 
 func (v *viewer) draw() {
+	if v.hidden {
+		return
+	}
+	if v.focus == Focusing(&v.prettyView) {
+		v.prettyView.draw()
+		v.info.draw()
+		termbox.Flush()
+		return
+	}
 	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
-	var chars []rune
-	var attrs []ansi.RuneAttr
-	var attr ansi.RuneAttr
-	var highlightStyle termbox.Attribute
-	var hlIndices [][]int
-	var hlChars int
-	var tx int
+	v.detectJSON()
+	var searchFunc filters.SearchFunc
+	if len(v.search) != 0 {
+		searchFunc, _ = filters.GetSearchFunc(v.info.searchType, v.search)
+	}
 	for ty, dataLine := 0, 0; ty < v.height; ty++ {
-		tx = 0
-		hlChars = 0
 		line, err := v.buffer.getLine(dataLine)
 		if err == io.EOF {
 			break
 		}
-		chars, attrs = v.replaceWithKeptChars(line.Str)
-		hlIndices = [][]int{}
-		if len(v.search) != 0 {
-			searchFunc, err := filters.GetSearchFunc(v.info.searchType, v.search)
-			if err == nil {
-				hlIndices = filters.IndexAll(searchFunc, chars)
+		if line.GapBefore && dataLine > 0 {
+			v.drawDivider(ty)
+			ty++
+			if ty >= v.height {
+				break
 			}
 		}
-		for i, char := range chars {
-			attr = attrs[i]
-			highlightStyle = termbox.Attribute(0)
-			if len(hlIndices) != 0 && hlChars == 0 {
-				if hlIndices[0][0] == i {
-					hlChars = hlIndices[0][1] - hlIndices[0][0]
-					hlIndices = hlIndices[1:]
+		label := line.Label
+		for r, row := range recordRows(line.Str) {
+			if r > 0 { // every line of the record starts on the new row
+				ty++
+				if ty >= v.height {
+					break
 				}
+				label = ansi.NewAstring([]byte(strings.Repeat(" ", len(line.Label.Runes))))
 			}
-			if hlChars != 0 {
-				highlightStyle = termbox.AttrReverse
-				hlChars--
-			}
-			if line.Highlighted {
-				highlightStyle = highlightStyle | termbox.AttrUnderline
-				attr.Bg = attr.Bg | ansi.FgColor(ansi.ColorYellow)
-			}
-
-			fg, bg := ToTermboxAttr(attr)
-
-			if highlightStyle != termbox.Attribute(0) {
-				fg = fg | highlightStyle
-			}
-			termbox.SetCell(tx, ty, char, fg, bg)
-			tx += runewidth.RuneWidth(char)
-			if tx >= v.width {
-				if v.wrap {
-					tx = 0
-					ty++
-				} else {
-					break
+			if v.json {
+				if record, ok := structured.Parse(string(row.Runes)); ok {
+					row = v.jsonTemplate.Render(record)
 				}
 			}
+			ty = v.drawRow(line, label, row, searchFunc, ty)
 		}
 		if ty >= v.height {
 			break
 		}
 		dataLine++
 	}
+	switch v.focus {
+	case Focusing(&v.filterManager):
+		v.filterManager.draw()
+	case Focusing(&v.presetPicker):
+		v.presetPicker.draw()
+	}
 	v.info.draw()
 	termbox.Flush()
 }
 
+// drawRow draws one line of the file on row ty after label, returns last row used, which is not ty only
+// if line is wrapped
+func (v *viewer) drawRow(line Line, label ansi.Astring, row ansi.Astring, searchFunc filters.SearchFunc, ty int) int {
+	var attr ansi.RuneAttr
+	var highlightStyle termbox.Attribute
+	chars, attrs := v.replaceWithKeptChars(row)
+	hlIndices := [][]int{}
+	if searchFunc != nil {
+		hlIndices = filters.IndexAll(searchFunc, chars)
+	}
+	hlChars := 0
+	gutter := 0
+	for i, char := range label.Runes {
+		fg, bg := ToTermboxAttr(label.Attrs[i])
+		termbox.SetCell(gutter, ty, char, fg, bg)
+		gutter += runewidth.RuneWidth(char)
+	}
+	tx := gutter
+	for i, char := range chars {
+		attr = attrs[i]
+		highlightStyle = termbox.Attribute(0)
+		if len(hlIndices) != 0 && hlChars == 0 {
+			if hlIndices[0][0] == i {
+				hlChars = hlIndices[0][1] - hlIndices[0][0]
+				hlIndices = hlIndices[1:]
+			}
+		}
+		if hlChars != 0 {
+			highlightStyle = termbox.AttrReverse
+			hlChars--
+		}
+		if line.Highlighted {
+			highlightStyle = highlightStyle | termbox.AttrUnderline
+			attr.Bg = attr.Bg | ansi.FgColor(ansi.ColorYellow)
+		}
+
+		fg, bg := ToTermboxAttr(attr)
+		if line.Highlighted && line.HighlightColor != termbox.ColorDefault {
+			bg = line.HighlightColor
+		}
+		if line.Context {
+			fg = contextLineColor
+		}
+
+		if highlightStyle != termbox.Attribute(0) {
+			fg = fg | highlightStyle
+		}
+		termbox.SetCell(tx, ty, char, fg, bg)
+		tx += runewidth.RuneWidth(char)
+		if tx >= v.width {
+			if v.wrap {
+				tx = gutter
+				ty++
+			} else {
+				break
+			}
+		}
+	}
+	return ty
+}
+
+// detectJSON enables JSON mode if lines on the screen are JSON objects. Decided once screen is full
+// or input is completely read
+func (v *viewer) detectJSON() {
+	if v.jsonDetected {
+		return
+	}
+	var lines []string
+	for i := 0; i < v.height; i++ {
+		line, err := v.buffer.getLine(i)
+		if err == io.EOF {
+			break
+		}
+		lines = append(lines, string(line.Str.Runes))
+	}
+	if len(lines) < v.height && v.fetcher.isStreaming() {
+		return
+	}
+	v.jsonDetected = true
+	v.json = v.json || structured.Detect(lines)
+}
+
+// Dimmed gray of 256 colors palette, termbox colors are shifted by one
+const contextLineColor = termbox.Attribute(245 + 1)
+
+// drawDivider separates groups of lines with context, which are not adjacent in the file
+func (v *viewer) drawDivider(y int) {
+	for x := 0; x < v.width; x++ {
+		termbox.SetCell(x, y, '-', contextLineColor, termbox.ColorDefault)
+	}
+}
+
 func (v *viewer) navigate(direction int) {
 	v.buffer.shift(direction)
 	v.following = false
-	if config.follow && !v.buffer.isFull() {
+	if v.followed && !v.buffer.isFull() {
 		v.following = true
 	}
 	v.draw()
@@ -311,7 +499,10 @@ func (v *viewer) navigate(direction int) {
 func (v *viewer) navigateEnd() {
 	v.buffer.reset(Pos{POS_UNKNOWN, v.fetcher.lastOffset()})
 	v.navigate(-v.height) //not adding +1 since nothing on screen now
-	if config.follow {
+	if v.buffer.fitEnd() {
+		v.draw()
+	}
+	if v.followed {
 		v.following = true
 	}
 }
@@ -374,6 +565,7 @@ func (v *viewer) processKey(ev termbox.Event) (a action) {
 		case 'U':
 			if ok := v.fetcher.removeLastFilter(); ok {
 				v.buffer.refresh()
+				v.recountMatches()
 				v.draw()
 			}
 		case 'g':
@@ -389,19 +581,40 @@ func (v *viewer) processKey(ev termbox.Event) (a action) {
 			v.info.reset(ibModeSearch)
 		case filters.FilterIntersectChar:
 			v.focus = &v.info
+			v.editedFilter = -1
 			v.info.reset(ibModeFilter)
 		case filters.FilterUnionChar:
 			v.focus = &v.info
+			v.editedFilter = -1
 			v.info.reset(ibModeAppend)
 		case filters.FilterExcludeChar:
 			v.focus = &v.info
+			v.editedFilter = -1
 			v.info.reset(ibModeExclude)
 		case filters.FilterHighlightChar:
 			v.focus = &v.info
+			v.editedFilter = -1
 			v.info.reset(ibModeHighlight)
+		case 'F':
+			v.filterManager.open()
+		case 'P':
+			v.presetPicker.open()
+		case 'J':
+			v.jsonDetected = true
+			v.json = !v.json
+			v.draw()
+		case 'E':
+			v.prettyView.open()
+		case 'c':
+			v.focus = &v.info
+			v.info.reset(ibModeContext)
+			v.info.setInput(v.fetcher.lineContext.String())
 		case '`':
 			v.fetcher.toggleHighlight(v.buffer.currentLine().Pos.Line)
 			v.buffer.toggleCurrentHighlight()
+			if len(v.fetcher.snapshotChain().filters) != 0 {
+				v.recountMatches() // highlighted lines are not excluded by filters
+			}
 			v.draw()
 		case '?':
 			v.focus = &v.info
@@ -415,6 +628,15 @@ func (v *viewer) processKey(ev termbox.Event) (a action) {
 		case 'K':
 			v.focus = &v.info
 			v.info.reset(ibModeKeepCharacters)
+		case ':':
+			v.focus = &v.info
+			v.info.reset(ibModeGoto)
+		case 'T':
+			v.focus = &v.info
+			v.info.reset(ibModeGotoTime)
+		case 't':
+			v.focus = &v.info
+			v.info.reset(ibModeTimeRange)
 		case 'j':
 			v.navigate(+1)
 		case 'k':
@@ -423,10 +645,22 @@ func (v *viewer) processKey(ev termbox.Event) (a action) {
 			v.navigateHorizontally(+1)
 		case '<':
 			v.navigateHorizontally(-1)
+		case 'X':
+			v.killCommand()
+		case 'R':
+			v.rerunCommand()
+		case ']':
+			return ACTION_NEXT_TAB
+		case '[':
+			return ACTION_PREV_TAB
 
 		}
 	} else {
 		switch ev.Key {
+		case termbox.KeyEsc:
+			if v.cancelTask() {
+				v.info.setMessage(ibMessage{str: "Canceled", color: termbox.ColorYellow})
+			}
 		case termbox.KeyArrowDown:
 			v.navigate(+1)
 		case termbox.KeyArrowUp:
@@ -482,81 +716,175 @@ type infobarRequest struct {
 	mode infobarMode
 }
 
+type statusUpdate struct {
+	v    *viewer
+	line LineNo
+}
+
 var requestSearch = make(chan infobarRequest)
-var requestRefresh = make(chan struct{})
-var requestRefill = make(chan struct{})
-var requestStatusUpdate = make(chan LineNo)
+var requestRefresh = make(chan *viewer)
+var requestRefill = make(chan *viewer)
+var requestTruncated = make(chan *viewer)
+var requestCommandChange = make(chan *viewer)
+var requestStatusUpdate = make(chan statusUpdate)
+var requestMatchesUpdate = make(chan *viewer)
 var requestKeepCharsChange = make(chan int)
+var requestReadError = make(chan viewerError)
+var requestCommandRestart = make(chan viewerError)
 
-func (v *viewer) termGui() {
-	err := termbox.Init()
-	if err != nil {
-		panic(err)
-	}
-	defer termbox.Close()
-
-	wg := sync.WaitGroup{}
-	ctx, cancel := context.WithCancel(v.ctx)
-	defer func() {
-		cancel()
-		wg.Wait()
-	}()
+type viewerError struct {
+	v   *viewer
+	err error
+}
 
-	termbox.SetInputMode(termbox.InputEsc)
-	termbox.SetOutputMode(termbox.Output256)
+func (v *viewer) init() {
 	v.info = infobar{
 		y:              0,
 		width:          0,
 		currentLine:    &v.buffer.originalPos,
 		totalLines:     0,
 		filtersEnabled: &v.fetcher.filtersEnabled,
+		lineContext:    &v.fetcher.lineContext,
+		json:           &v.json,
 		keepChars:      &v.keepChars,
 		flock:          &v.fetcher.lock,
 		searchType:     filters.CaseSensitive,
+		label:          v.label,
+		command:        v.command,
+		matches:        &v.matches,
 	}
 	v.focus = v
+	v.filterManager = filterManager{v: v}
+	v.presetPicker = presetPicker{v: v}
+	v.prettyView = prettyView{v: v}
+	v.editedFilter = -1
 	v.buffer = viewBuffer{
 		fetcher: v.fetcher,
 	}
 	v.resize(termbox.Size())
-	if config.follow {
+	if v.followed {
 		v.navigateEnd()
 	}
+	v.navigateToStart(v.start)
+}
+
+// runBackground starts goroutines watching for changes of underlying data
+func (v *viewer) runBackground(ctx context.Context, wg *sync.WaitGroup) {
 	wg.Add(3)
 	go func() { v.refreshIfEmpty(ctx); wg.Done() }()
 	go func() { v.updateLastLine(ctx); wg.Done() }()
 	go func() { v.follow(ctx); wg.Done() }()
+	if v.command != nil {
+		wg.Add(1)
+		go func() { v.watchCommand(ctx); wg.Done() }()
+	}
+	if v.readErr != nil {
+		wg.Add(1)
+		go func() { v.watchReadError(ctx); wg.Done() }()
+	}
+	if v.start.kind == startSearch {
+		wg.Add(1)
+		go func() { v.searchStart(ctx); wg.Done() }()
+	}
+}
+
+func termGui(viewers []*viewer) {
+	err := termbox.Init()
+	if err != nil {
+		panic(err)
+	}
+	defer termbox.Close()
+
+	wg := sync.WaitGroup{}
+	cancels := make([]context.CancelFunc, 0, len(viewers))
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+		for _, v := range viewers {
+			v.stopTask()
+		}
+		wg.Wait()
+	}()
+
+	termbox.SetInputMode(termbox.InputEsc)
+	termbox.SetOutputMode(termbox.Output256)
+	t := &tabs{viewers: viewers}
+	for i, v := range viewers {
+		if len(viewers) > 1 {
+			v.label = fmt.Sprintf("%d/%d %s", i+1, len(viewers), v.name)
+		}
+		v.hidden = i != t.current
+		v.init()
+		ctx, cancel := context.WithCancel(v.ctx)
+		cancels = append(cancels, cancel)
+		v.runBackground(ctx, &wg)
+	}
 loop:
 	for {
 		switch ev := termbox.PollEvent(); ev.Type {
 		case termbox.EventKey:
+			v := t.active()
 			action := v.focus.processKey(ev)
 			switch action {
 			case ACTION_QUIT:
 				break loop
 			case ACTION_RESET_FOCUS:
 				v.resetFocus()
+			case ACTION_NEXT_TAB:
+				t.switchTab(+1)
+			case ACTION_PREV_TAB:
+				t.switchTab(-1)
 			}
 		case termbox.EventResize:
 			logging.Debug("Resize event", ev.Width, ev.Height)
-			v.resize(ev.Width, ev.Height)
+			for _, v := range t.viewers {
+				v.resize(ev.Width, ev.Height)
+			}
 		case termbox.EventError:
 			panic(ev.Err)
 		case termbox.EventInterrupt:
 			select {
 			case search := <-requestSearch:
-				v.processInfobarRequest(search)
-			case <-requestRefresh:
+				t.active().processInfobarRequest(search)
+			case v := <-requestRefresh:
 				v.buffer.refresh()
 				v.draw()
-			case <-requestRefill: // It is not most efficient solution, it might cause huge amount of redraws
+			case v := <-requestRefill: // It is not most efficient solution, it might cause huge amount of redraws
 				v.refill()
-			case line := <-requestStatusUpdate:
-				v.info.totalLines = line + 1
-				if v.focus == v {
+			case v := <-requestTruncated:
+				v.onTruncated()
+			case v := <-requestCommandChange:
+				v.buffer.refresh()
+				v.draw()
+			case update := <-requestStatusUpdate:
+				v := update.v
+				v.info.totalLines = update.line + 1
+				if current := v.buffer.currentLine().Pos; current.Line == POS_UNKNOWN &&
+					v.fetcher.resolveLine(current.Offset) != POS_UNKNOWN {
+					v.buffer.refresh() // line numbers became known
+					v.draw()
+				} else if v.focus == v && !v.hidden {
 					v.info.draw()
 				}
+			case v := <-requestMatchesUpdate:
+				if v.focus == v && !v.hidden && v.info.mode == ibModeStatus {
+					v.info.draw()
+				}
+			case failure := <-requestReadError:
+				v := failure.v
+				v.info.setMessage(ibMessage{str: "Err: input is cut short, " + failure.err.Error(), color: termbox.ColorRed})
+				v.draw()
+			case found := <-requestStartFound:
+				found.v.onStartFound(found.pos)
+			case done := <-requestTaskDone:
+				done.v.onTaskDone(done)
+			case progress := <-requestTaskProgress:
+				progress.v.onTaskProgress(progress)
+			case restart := <-requestCommandRestart:
+				restart.v.onCommandRestarted(restart.err)
 			case charChange := <-requestKeepCharsChange:
+				v := t.active()
 				if v.keepChars+charChange >= 0 {
 					v.keepChars = v.keepChars + charChange
 				}
@@ -611,7 +939,7 @@ func (v *viewer) refreshIfEmpty(ctx context.Context) {
 	refresh := func() {
 		go termbox.Interrupt()
 		select {
-		case requestRefresh <- struct{}{}:
+		case requestRefresh <- v:
 		case <-ctx.Done():
 			return
 		}
@@ -638,7 +966,7 @@ loop:
 		case <-ctx.Done():
 			break loop
 		case <-time.After(delay):
-			if config.follow {
+			if v.followed {
 				break loop
 			}
 			lock()
@@ -648,11 +976,11 @@ loop:
 			if v.buffer.pos != 0 || v.buffer.resetPos.Offset != 0 {
 				break loop
 			}
-			if len(v.fetcher.filters) != 0 && !config.stdin {
+			if len(v.fetcher.snapshotChain().filters) != 0 && !v.fetcher.isStream() {
 				break loop
 			}
 			unlock()
-			if config.stdin && config.isStdinRead() {
+			if v.fetcher.isStream() && !v.fetcher.isStreaming() {
 				refresh()
 				break loop
 			}
@@ -663,35 +991,26 @@ loop:
 	unlock()
 }
 
+// updateLastLine keeps total number of lines shown in status bar up to date with line index
 func (v *viewer) updateLastLine(ctx context.Context) {
 	delay := 10 * time.Millisecond
-	lastLine := Pos{0, 0}
-	var dataLine PosLine
-loop:
+	total := LineNo(-1)
 	for {
 		select {
 		case <-ctx.Done():
-			break loop
+			return
 		case <-time.After(delay):
-			prevLine := lastLine
-			dataLine = v.fetcher.advanceLines(lastLine)
-			lastLine = dataLine.Pos
-			if lastLine != prevLine {
-				go termbox.Interrupt()
-				select {
-				case requestStatusUpdate <- lastLine.Line:
-					v.fetcher.updateMap(dataLine)
-				case <-ctx.Done():
-					return
-				}
-				delay = 0
-			} else if config.stdin && config.isStdinRead() {
-				break loop
-			} else {
-				if delay == 0 {
-					delay = 10 * time.Millisecond
-				}
-				delay = time.Duration(utils.Min64(int64(4000*time.Millisecond), int64(delay*2)))
+			delay = 100 * time.Millisecond
+			newTotal := v.fetcher.index.total()
+			if newTotal == total {
+				continue
+			}
+			total = newTotal
+			go termbox.Interrupt()
+			select {
+			case requestStatusUpdate <- statusUpdate{v, total - 1}:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}
@@ -699,33 +1018,121 @@ loop:
 
 func (v *viewer) follow(ctx context.Context) {
 	delay := 100 * time.Millisecond
-	lastOffset := v.fetcher.lastOffset()
+	size := v.fetcher.size()
+	request := func(ch chan *viewer) {
+		go func() {
+			go termbox.Interrupt()
+			select {
+			case ch <- v:
+			case <-ctx.Done():
+				return
+			}
+		}()
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-time.After(delay):
-			if !config.follow {
+			if !v.followed {
 				continue
 			}
-			if v.following {
-				prevOffset := lastOffset
-				lastOffset = v.fetcher.lastOffset()
-				if lastOffset != prevOffset {
-					go func() {
-						go termbox.Interrupt()
-						select {
-						case requestRefill <- struct{}{}:
-						case <-ctx.Done():
-							return
-						}
-					}()
-				}
+			prevSize := size
+			size = v.fetcher.size()
+			if size < prevSize {
+				request(requestTruncated)
+				continue
+			}
+			if v.following && size != prevSize {
+				request(requestRefill)
+			}
+		}
+	}
+}
+
+// onTruncated starts following file from the beginning, once it was truncated(i.e. by logrotate copytruncate)
+func (v *viewer) onTruncated() {
+	v.startOver()
+	v.info.setMessage(ibMessage{str: "File truncated, following from the new start", color: termbox.ColorYellow})
+}
+
+// startOver drops everything known about the data, which will be read again from the beginning
+func (v *viewer) startOver() {
+	v.fetcher.reset()
+	v.info.totalLines = 0
+	v.buffer.reset(Pos{0, 0})
+	v.following = v.followed
+	v.draw()
+}
+
+// watchCommand redraws view every time command is started or finished
+func (v *viewer) watchCommand(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-v.command.changed:
+			go termbox.Interrupt()
+			select {
+			case requestCommandChange <- v:
+			case <-ctx.Done():
+				return
 			}
 		}
 	}
 }
 
+// watchReadError shows error of reading input, once it fails
+func (v *viewer) watchReadError(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case err := <-v.readErr:
+		go termbox.Interrupt()
+		select {
+		case requestReadError <- viewerError{v, err}:
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (v *viewer) killCommand() {
+	if v.command == nil {
+		return
+	}
+	v.command.kill()
+}
+
+// rerunCommand restarts command in background, old one might take a while to finish
+func (v *viewer) rerunCommand() {
+	if v.command == nil || v.restarting {
+		return
+	}
+	v.restarting = true
+	v.info.setMessage(ibMessage{str: "Restarting command...", color: termbox.ColorYellow})
+	v.draw()
+	v.command.wg.Add(1)
+	go func() {
+		defer v.command.wg.Done()
+		err := v.command.restart()
+		go termbox.Interrupt()
+		select {
+		case requestCommandRestart <- viewerError{v, err}:
+		case <-v.ctx.Done():
+		}
+	}()
+}
+
+func (v *viewer) onCommandRestarted(err error) {
+	v.restarting = false
+	if err != nil {
+		v.info.setMessage(ibMessage{str: "Err:" + err.Error(), color: termbox.ColorRed})
+		v.draw()
+		return
+	}
+	v.fetcher.setStreamDone(v.command.streamDone())
+	v.startOver()
+}
+
 func (v *viewer) processInfobarRequest(search infobarRequest) {
 	defer logging.Timeit("Got search request")()
 	switch search.mode {
@@ -739,13 +1146,30 @@ func (v *viewer) processInfobarRequest(search infobarRequest) {
 		v.addFilter(search.str, filters.FilterHighlight)
 	case ibModeSave:
 		v.saveFiltered(string(search.str))
+	case ibModeSavePreset:
+		v.savePreset(string(search.str))
+	case ibModeContext:
+		lineContext, err := parseContextLines(string(search.str))
+		if err != nil {
+			v.showSearchError(err)
+			break
+		}
+		v.setContext(lineContext)
+	case ibModeGoto:
+		v.goTo(string(search.str))
+	case ibModeGotoTime:
+		v.goToTime(string(search.str))
+	case ibModeTimeRange:
+		v.addTimeRange(search.str)
 	case ibModeSearch:
 		v.search = search.str
 		v.forwardSearch = true
+		v.recountMatches()
 		v.nextSearch(false)
 	case ibModeBackSearch:
 		v.search = search.str
 		v.forwardSearch = false
+		v.recountMatches()
 		v.nextSearch(false)
 	case ibModeKeepCharacters:
 		keep, err := strconv.Atoi(string(search.str))
@@ -783,6 +1207,7 @@ func (v *viewer) dropFilters() {
 	v.fetcher.filters = newFilters
 	v.fetcher.lock.Unlock()
 	v.buffer.refresh()
+	v.recountMatches()
 	v.draw()
 }
 
@@ -795,7 +1220,7 @@ func (v *viewer) dropHighlights() {
 		}
 	}
 	v.fetcher.filters = newFilters
-	v.fetcher.highlightedLines = v.fetcher.highlightedLines[:0]
+	v.fetcher.highlightedLines = nil
 	v.fetcher.lock.Unlock()
 	v.buffer.refresh()
 	v.draw()
@@ -0,0 +1,191 @@
+package slit
+
+import (
+	"context"
+	"io"
+	"math"
+	"time"
+
+	"github.com/tigrawap/slit/filters"
+	"github.com/tigrawap/slit/timestamps"
+)
+
+// noBound is the end of lines range, which is not limited
+const noBound = Offset(math.MaxInt64)
+
+// Number of timestamps checked across the file to decide if it is sorted by time
+const timeSortSamples = 8
+
+// timeBounds are offsets of the first line kept by time filter and of the first line after the range
+type timeBounds struct {
+	filter   *filters.Filter // bounds are found for this filter
+	from, to Offset
+}
+
+// recordStartFunc returns func telling if line starts a record, nil if every line is a record on its own.
+// While lines are filtered by time, lines without timestamp are continuation of the previous line, i.e. stack trace
+func (f *Fetcher) recordStartFunc(chain filterChain) func([]byte) bool {
+	if f.recordStart != nil {
+		return f.recordStart.Match
+	}
+	if !chain.timeFiltered() {
+		return nil
+	}
+	var parser timestamps.Parser
+	return func(line []byte) bool {
+		if len(line) > timestamps.SearchWindow {
+			line = line[:timestamps.SearchWindow]
+		}
+		_, ok := parser.Parse(string(line))
+		return ok
+	}
+}
+
+// anchorTimeRanges takes single time of day bounds of Time filters, i.e. --since=14:00, on the day log starts,
+// instead of the day of whichever line is checked first
+func (f *Fetcher) anchorTimeRanges(chain filterChain) {
+	for _, filter := range chain.filters {
+		timeRange, ok := filter.TimeRange()
+		if !ok || timeRange.Anchored() {
+			continue
+		}
+		var parser timestamps.Parser
+		if first, _, ok := f.timestampAfter(0, &parser, timeSearchMaxLines); ok {
+			timeRange.Anchor(first)
+		}
+	}
+}
+
+// timeFiltered returns true if enabled Time filter is in the chain
+func (c filterChain) timeFiltered() bool {
+	if !c.filtersEnabled {
+		return false
+	}
+	for _, filter := range c.filters {
+		if _, ok := filter.TimeRange(); ok && !filter.Disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// boundingTimeFilter returns last enabled intersecting Time filter, nil if there is none or if lines excluded
+// by it might be shown anyway, by following union and highlight filters, highlights or as context
+func (f *Fetcher) boundingTimeFilter() *filters.Filter {
+	if !f.filtersEnabled || len(f.highlightedLines) != 0 || f.lineContext.enabled() {
+		return nil
+	}
+	var bounding *filters.Filter
+	for _, filter := range f.filters {
+		if filter.Disabled {
+			continue
+		}
+		switch filter.Action {
+		case filters.FilterIntersect:
+			if _, ok := filter.TimeRange(); ok {
+				bounding = filter
+			}
+		case filters.FilterUnion, filters.FilterHighlight:
+			bounding = nil
+		}
+	}
+	return bounding
+}
+
+// timeBounds returns offsets of the first line within the range of time filter and of the first line after it,
+// lines outside of them are excluded by the filter and not read at all.
+// Only known if file is sorted by time, whole file is returned otherwise.
+// Bounds are found once per filter, lines appended later are after both of them, so they stay valid while following
+func (f *Fetcher) timeBounds(ctx context.Context) (from, to Offset) {
+	f.lock.RLock()
+	filter := f.boundingTimeFilter()
+	f.lock.RUnlock()
+	if filter == nil {
+		return 0, noBound
+	}
+	f.mLock.RLock()
+	bounds := f.bounds
+	f.mLock.RUnlock()
+	if bounds.filter == filter {
+		return bounds.from, bounds.to
+	}
+	if f.size() == 0 {
+		return 0, noBound // nothing to check yet
+	}
+	from, to, err := f.findTimeBounds(ctx, filter)
+	if err != nil {
+		return 0, noBound
+	}
+	f.mLock.Lock()
+	f.bounds = timeBounds{filter: filter, from: from, to: to}
+	f.mLock.Unlock()
+	return from, to
+}
+
+func (f *Fetcher) findTimeBounds(ctx context.Context, filter *filters.Filter) (from, to Offset, err error) {
+	timeRange, _ := filter.TimeRange()
+	var parser timestamps.Parser
+	first, last, sorted := f.sortedByTime(&parser)
+	if !sorted {
+		return 0, noBound, nil
+	}
+	if timeRange.TimeOfDay() && !sameDay(first, last) {
+		return 0, noBound, nil // range is matched on every day of the file
+	}
+	since, until := timeRange.Bounds(first)
+	from, to = 0, noBound
+	if !since.IsZero() {
+		from, err = f.timeOffset(ctx, since)
+		if err == io.EOF {
+			return f.size(), noBound, nil // lines appended later might be in range
+		}
+		if err != nil {
+			return 0, noBound, err
+		}
+	}
+	if !until.IsZero() {
+		to, err = f.timeOffset(ctx, until)
+		if err == io.EOF {
+			return from, noBound, nil
+		}
+		if err != nil {
+			return 0, noBound, err
+		}
+	}
+	return from, to, nil
+}
+
+// sortedByTime checks timestamps evenly spread across the file, returns the first and the last of them
+// and whether they are in order
+func (f *Fetcher) sortedByTime(parser *timestamps.Parser) (first, last time.Time, sorted bool) {
+	size := f.size()
+	found := 0
+	for i := 0; i <= timeSortSamples; i++ {
+		offset := size * Offset(i) / timeSortSamples
+		if i == timeSortSamples { // last one is close to the end
+			offset = size - timeSearchLinear
+			if offset < 0 {
+				offset = 0
+			}
+		}
+		ts, _, ok := f.timestampAfter(offset, parser, timeSearchMaxLines)
+		if !ok {
+			continue
+		}
+		if found != 0 && ts.Before(last) {
+			return first, last, false
+		}
+		if found == 0 {
+			first = ts
+		}
+		last = ts
+		found++
+	}
+	return first, last, found != 0
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
@@ -0,0 +1,142 @@
+package timestamps
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SearchWindow is the length of the beginning of the line inspected, timestamps are rarely placed further
+const SearchWindow = 256
+
+type format struct {
+	re      *regexp.Regexp
+	layouts []string
+	noYear  bool
+}
+
+var formats = []format{
+	{ // ISO8601/RFC3339, log4j and python logging
+		re: regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`),
+		layouts: []string{
+			"2006-01-02T15:04:05Z07:00",
+			"2006-01-02T15:04:05-0700",
+			"2006-01-02T15:04:05",
+		},
+	},
+	{ // nginx error log, go log package
+		re:      regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:[.,]\d+)?`),
+		layouts: []string{"2006/01/02 15:04:05"},
+	},
+	{ // apache/nginx access log
+		re:      regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`),
+		layouts: []string{"02/Jan/2006:15:04:05 -0700"},
+	},
+	{ // syslog
+		re:      regexp.MustCompile(`[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:[.,]\d+)?`),
+		layouts: []string{time.Stamp},
+		noYear:  true,
+	},
+}
+
+// Parser detects timestamps in lines. Layout of last successful match is tried first,
+// since lines of the same file usually share it. Parser is not safe for concurrent use
+type Parser struct {
+	last int
+}
+
+// Parse returns first timestamp found in the beginning of line
+func (p *Parser) Parse(line string) (time.Time, bool) {
+	if len(line) > SearchWindow {
+		line = line[:SearchWindow]
+	}
+	if t, ok := parseFormat(formats[p.last], line); ok {
+		return t, true
+	}
+	for i, f := range formats {
+		if i == p.last {
+			continue
+		}
+		if t, ok := parseFormat(f, line); ok {
+			p.last = i
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Parse returns first timestamp found in the beginning of line
+func Parse(line string) (time.Time, bool) {
+	var p Parser
+	return p.Parse(line)
+}
+
+func parseFormat(f format, line string) (time.Time, bool) {
+	loc := f.re.FindStringIndex(line)
+	if loc == nil {
+		return time.Time{}, false
+	}
+	value := strings.Replace(line[loc[0]:loc[1]], ",", ".", 1)
+	if len(value) > 10 && value[10] == ' ' && value[4] == '-' {
+		value = value[:10] + "T" + value[11:]
+	}
+	for _, layout := range f.layouts {
+		t, err := time.ParseInLocation(layout, value, time.Local)
+		if err != nil {
+			continue
+		}
+		if f.noYear {
+			t = t.AddDate(time.Now().Year(), 0, 0)
+		}
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+var queryLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"02/Jan/2006:15:04:05",
+}
+
+var timeOfDayLayouts = []string{
+	"15:04:05",
+	"15:04",
+}
+
+// ParseTimeOfDay parses time of day typed by user without date, i.e. 14:32 or 14:32:05,
+// returns time passed since midnight
+func ParseTimeOfDay(query string) (time.Duration, bool) {
+	query = strings.TrimSpace(query)
+	for _, layout := range timeOfDayLayouts {
+		if t, err := time.Parse(layout, query); err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+				time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond()), true
+		}
+	}
+	return 0, false
+}
+
+// ParseQuery parses time typed by user, in local timezone.
+// Time of day without date is taken on the same day as reference
+func ParseQuery(query string, reference time.Time) (time.Time, error) {
+	query = strings.TrimSpace(query)
+	for _, layout := range queryLayouts {
+		if t, err := time.ParseInLocation(layout, query, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	var err error
+	for _, layout := range timeOfDayLayouts {
+		var t time.Time
+		if t, err = time.ParseInLocation(layout, query, time.Local); err == nil {
+			year, month, day := reference.Date()
+			return time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), reference.Location()), nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,107 @@
+package timestamps
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	utc := time.UTC
+	local := time.Local
+	tests := []struct {
+		line     string
+		expected time.Time
+	}{
+		{"2019-03-01T14:32:05Z INFO started", time.Date(2019, 3, 1, 14, 32, 5, 0, utc)},
+		{"2019-03-01T14:32:05+02:00 INFO", time.Date(2019, 3, 1, 12, 32, 5, 0, utc)},
+		{"2019-03-01T14:32:05+0200 INFO", time.Date(2019, 3, 1, 12, 32, 5, 0, utc)},
+		{"2019-03-01 14:32:05,123 INFO log4j", time.Date(2019, 3, 1, 14, 32, 5, 123e6, local)},
+		{"2019-03-01 14:32:05.5 python", time.Date(2019, 3, 1, 14, 32, 5, 5e8, local)},
+		{"[main] 2019-03-01 14:32:05 prefixed", time.Date(2019, 3, 1, 14, 32, 5, 0, local)},
+		{"2019/03/01 14:32:05 [error] nginx", time.Date(2019, 3, 1, 14, 32, 5, 0, local)},
+		{`127.0.0.1 - - [01/Mar/2019:14:32:05 +0000] "GET / HTTP/1.1"`, time.Date(2019, 3, 1, 14, 32, 5, 0, utc)},
+		{"Mar  1 14:32:05 host sshd[1]:", time.Date(time.Now().Year(), 3, 1, 14, 32, 5, 0, local)},
+		{"Mar 11 14:32:05 host sshd[1]:", time.Date(time.Now().Year(), 3, 11, 14, 32, 5, 0, local)},
+	}
+	var p Parser
+	for _, test := range tests {
+		for _, parse := range []func(string) (time.Time, bool){Parse, p.Parse} {
+			got, ok := parse(test.line)
+			if !ok {
+				t.Errorf("%q: timestamp not found", test.line)
+				continue
+			}
+			if !got.Equal(test.expected) {
+				t.Errorf("%q: got %v, expected %v", test.line, got, test.expected)
+			}
+		}
+	}
+}
+
+func TestParseNotFound(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"no timestamp here",
+		"version 2019-03-01",
+		"2019-13-01 14:32:05 bad month",
+		string(make([]byte, SearchWindow)) + "2019-03-01 14:32:05 too far",
+	} {
+		if got, ok := Parse(line); ok {
+			t.Errorf("%q: unexpected timestamp %v", line, got)
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	reference := time.Date(2019, 3, 1, 10, 0, 0, 0, time.Local)
+	tests := []struct {
+		query    string
+		expected time.Time
+	}{
+		{"2019-03-02 14:32:05", time.Date(2019, 3, 2, 14, 32, 5, 0, time.Local)},
+		{"2019-03-02T14:32", time.Date(2019, 3, 2, 14, 32, 0, 0, time.Local)},
+		{" 2019-03-02 ", time.Date(2019, 3, 2, 0, 0, 0, 0, time.Local)},
+		{"2019/03/02 14:32:05", time.Date(2019, 3, 2, 14, 32, 5, 0, time.Local)},
+		{"02/Mar/2019:14:32:05", time.Date(2019, 3, 2, 14, 32, 5, 0, time.Local)},
+		{"14:32:05", time.Date(2019, 3, 1, 14, 32, 5, 0, time.Local)},
+		{"14:32", time.Date(2019, 3, 1, 14, 32, 0, 0, time.Local)},
+	}
+	for _, test := range tests {
+		got, err := ParseQuery(test.query, reference)
+		if err != nil {
+			t.Errorf("%q: %v", test.query, err)
+			continue
+		}
+		if !got.Equal(test.expected) {
+			t.Errorf("%q: got %v, expected %v", test.query, got, test.expected)
+		}
+	}
+	for _, query := range []string{"", "tomorrow", "25:00", "2019-03-32"} {
+		if _, err := ParseQuery(query, reference); err == nil {
+			t.Errorf("%q: expected error", query)
+		}
+	}
+}
+
+func TestParseTimeOfDay(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected time.Duration
+	}{
+		{"14:32", 14*time.Hour + 32*time.Minute},
+		{"14:32:05", 14*time.Hour + 32*time.Minute + 5*time.Second},
+		{" 00:00 ", 0},
+		{"23:59:59", 24*time.Hour - time.Second},
+	}
+	for _, test := range tests {
+		got, ok := ParseTimeOfDay(test.query)
+		if !ok || got != test.expected {
+			t.Errorf("%q: got %v %v, expected %v", test.query, got, ok, test.expected)
+		}
+	}
+	for _, query := range []string{"", "14", "24:00", "2019-03-01 14:32", "30m"} {
+		if _, ok := ParseTimeOfDay(query); ok {
+			t.Errorf("%q: expected to fail", query)
+		}
+	}
+}
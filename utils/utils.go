@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 )
 
 func Check(e error) {
@@ -74,6 +75,43 @@ func ValidateRegularFile(filename string) error {
 	return nil
 }
 
+// ValidateInputFile checks if file can be used as an input. Returns false for streams(named pipes,
+// devices, pseudo files of /proc and /sys), which can be read only once and have to be cached
+func ValidateInputFile(filename string) (seekable bool, err error) {
+	fi, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return false, errors.New(filename + ": No such file or directory")
+	} else if os.IsPermission(err) {
+		return false, errors.New(filename + ": Permission denied")
+	} else if err != nil {
+		return false, err
+	}
+	switch fmode := fi.Mode(); {
+	case fmode.IsDir():
+		return false, errors.New(filename + " is a directory")
+	case fmode.IsRegular():
+		return fi.Size() != 0 || !isPseudoFile(filename), nil
+	}
+	return false, nil
+}
+
+// isPseudoFile returns true for files generated by kernel, their size is reported as zero
+func isPseudoFile(filename string) bool {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	for _, dir := range []string{"/proc/", "/sys/"} {
+		if strings.HasPrefix(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
 func GetHomeDir() string {
 	currentUser, err := user.Current()
 	var homedir string
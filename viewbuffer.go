@@ -245,7 +245,55 @@ func (b *viewBuffer) shiftToEnd() {
 		return
 	}
 	b.pos = len(b.buffer) - b.window
+	b.originalPos = b.buffer[b.pos].Pos
+	b.fitRows()
 }
+
+// fitEnd keeps last line on the screen, once end of the buffer is shown
+func (b *viewBuffer) fitEnd() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.fitRows()
+}
+
+// fitRows moves view down, if records of several lines or dividers between lines with context
+// take rows needed by the last lines
+func (b *viewBuffer) fitRows() bool {
+	if len(b.buffer) == 0 || len(b.buffer)-b.pos > b.window {
+		return false
+	}
+	top := len(b.buffer) - 1
+	rows := lineRows(b.buffer[top])
+	for top > b.pos {
+		next := rows + lineRows(b.buffer[top-1])
+		if b.buffer[top].GapBefore {
+			next++
+		}
+		if next > b.window {
+			break
+		}
+		top--
+		rows = next
+	}
+	if top == b.pos {
+		return false
+	}
+	b.pos = top
+	b.originalPos = b.buffer[b.pos].Pos
+	return true
+}
+
+// lineRows returns number of rows line takes on the screen, not counting wrapping
+func lineRows(l Line) int {
+	rows := 1
+	for _, r := range l.Str.Runes {
+		if r == '\n' {
+			rows++
+		}
+	}
+	return rows
+}
+
 func (b *viewBuffer) toggleCurrentHighlight() {
 	b.buffer[b.pos].Highlighted = !b.buffer[b.pos].Highlighted
 }